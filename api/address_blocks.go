@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+var regexpInstanceKey = regexp.MustCompile(`\[[^\]]*\]`)
+
+// declaredAddress returns the address of a resource, data or module block, e.g. `aws_instance.web`.
+func declaredAddress(block *hclwrite.Block) (string, bool) {
+	labels := block.Labels()
+	switch {
+	case (block.Type() == "resource" || block.Type() == "data") && len(labels) == 2,
+		block.Type() == "module" && len(labels) == 1:
+		return blockAddress{blockType: block.Type(), labels: labels}.String(), true
+	}
+	return "", false
+}
+
+// addressContains reports whether the address is the same as, or is inside of, the container address.
+// Instance keys are ignored, so `module.app[0].aws_instance.web` is inside of `module.app`.
+func addressContains(container string, address string) bool {
+	container = regexpInstanceKey.ReplaceAllString(container, "")
+	address = regexpInstanceKey.ReplaceAllString(address, "")
+	return address == container || strings.HasPrefix(address, container+".")
+}
+
+// validateAddressBlocks reports moved, import and removed blocks which contradict each other
+// or the declared blocks, before Terraform does.
+// Conflicting moved blocks are errors. Removed addresses which are still declared or imported are
+// only warned, because existing configurations may have them and Terraform reports them on plan.
+func validateAddressBlocks(body *hclwrite.Body) error {
+	var errs []error
+
+	declared := []string{}
+	movedTo := map[string]string{}
+	movedFrom := map[string]string{}
+	imports := []string{}
+	removed := []string{}
+
+	for _, block := range body.Blocks() {
+		if address, ok := declaredAddress(block); ok {
+			declared = append(declared, address)
+			continue
+		}
+
+		switch block.Type() {
+		case "moved":
+			from := attributeSource(block.Body(), "from")
+			to := attributeSource(block.Body(), "to")
+			if existingTo, ok := movedTo[from]; ok && existingTo != to {
+				errs = append(errs, fmt.Errorf("%s is moved to both %s and %s", from, existingTo, to))
+				continue
+			}
+			if existingFrom, ok := movedFrom[to]; ok && existingFrom != from {
+				errs = append(errs, fmt.Errorf("both %s and %s are moved to %s", existingFrom, from, to))
+				continue
+			}
+			movedTo[from] = to
+			movedFrom[to] = from
+		case "import":
+			imports = append(imports, attributeSource(block.Body(), "to"))
+		case "removed":
+			removed = append(removed, attributeSource(block.Body(), "from"))
+		}
+	}
+
+	froms := make([]string, 0, len(movedTo))
+	for from := range movedTo {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	for _, from := range froms {
+		visited := map[string]bool{from: true}
+		chain := []string{from}
+		for next, ok := movedTo[from]; ok; next, ok = movedTo[next] {
+			chain = append(chain, next)
+			if visited[next] {
+				// Report a cycle only once, from its lexically smallest address.
+				if next == from && isSmallest(from, chain) {
+					errs = append(errs, fmt.Errorf("moved blocks form a cycle: %s", strings.Join(chain, " -> ")))
+				}
+				break
+			}
+			visited[next] = true
+		}
+	}
+
+	for _, from := range removed {
+		for _, address := range declared {
+			if addressContains(from, address) {
+				slog.Warn("The address is removed by a removed block but it is still declared", "address", address)
+			}
+		}
+		for _, to := range imports {
+			if addressContains(from, to) {
+				slog.Warn("The address is imported but it is removed by a removed block", "address", to)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func isSmallest(s string, list []string) bool {
+	for _, item := range list {
+		if item < s {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestMergeFileBlocksAddressBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		expect  string
+		wantErr bool
+	}{
+		{
+			name: "identical blocks are de-duplicated",
+			base: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+import {
+  to = aws_instance.b
+  id = "i-abcd1234"
+}
+`,
+			overlay: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+import {
+  to = aws_instance.b
+  id = "i-abcd1234"
+}
+`,
+			expect: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+import {
+  to = aws_instance.b
+  id = "i-abcd1234"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "identical blocks only in the overlay are de-duplicated",
+			base: `resource "aws_instance" "b" {
+}
+`,
+			overlay: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+`,
+			expect: `resource "aws_instance" "b" {
+}
+moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "removed block of the same address is replaced",
+			base: `removed {
+  from = aws_instance.a
+  lifecycle {
+    destroy = false
+  }
+}
+`,
+			overlay: `removed {
+  from = aws_instance.a
+  lifecycle {
+    destroy = true
+  }
+}
+`,
+			expect: `removed {
+  from = aws_instance.a
+  lifecycle {
+    destroy = true
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "moved chain is allowed",
+			base: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+`,
+			overlay: `moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+`,
+			expect: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "moved cycle",
+			base: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+`,
+			overlay: `moved {
+  from = aws_instance.b
+  to   = aws_instance.a
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "moved to different addresses",
+			base: `moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+`,
+			overlay: `moved {
+  from = aws_instance.a
+  to   = aws_instance.c
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "moved from different addresses",
+			base: `moved {
+  from = aws_instance.a
+  to   = aws_instance.c
+}
+`,
+			overlay: `moved {
+  from = aws_instance.b
+  to   = aws_instance.c
+}
+`,
+			wantErr: true,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseHCL, diags := hclwrite.ParseConfig([]byte(tt.base), "base.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			overlayHCL, diags := hclwrite.ParseConfig([]byte(tt.overlay), "overlay.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.MergeFileBlocks(baseHCL, overlayHCL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MergeFileBlocks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
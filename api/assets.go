@@ -0,0 +1,80 @@
+package api
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CollectAssets returns the files to copy into the output directory, keyed by their paths relative to it.
+// A directory is copied as a directory of the same name, and a file is copied into the top of the output directory.
+// A later path replaces files of the same relative path in earlier ones, so overlay assets should follow base assets.
+// Files which are merged instead, such as .tf, .tfvars, .tftest.hcl and .terraform.lock.hcl files, and
+// tfustomization.hcl are skipped, as well as .terraform directories.
+func (p HCLParser) CollectAssets(baseDir string, paths []string) (map[string]string, error) {
+	assets := map[string]string{}
+
+	for _, path := range paths {
+		fullPath := filepath.Join(baseDir, path)
+		fileInfo, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		if !fileInfo.IsDir() {
+			assets[fileInfo.Name()] = fullPath
+			continue
+		}
+
+		err = filepath.WalkDir(fullPath, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() && entry.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			if entry.IsDir() || isMergedFile(entry.Name()) {
+				return nil
+			}
+			rel, err := filepath.Rel(fullPath, filePath)
+			if err != nil {
+				return err
+			}
+			assets[filepath.Join(fileInfo.Name(), rel)] = filePath
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return assets, nil
+}
+
+// isMergedFile reports whether the file is merged or read by tfustomize, so it's not an asset.
+func isMergedFile(name string) bool {
+	return isHCLFile(name) || isJSONConfigFile(name) || isTfvarsFile(name) || isTestFile(name) ||
+		name == lockFileName || name == "tfustomization.hcl"
+}
+
+// CopyAssets copies the assets collected by CollectAssets into outputDir.
+func (p HCLParser) CopyAssets(assets map[string]string, outputDir string) error {
+	for rel, source := range assets {
+		fileInfo, err := os.Stat(source)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+
+		destination := filepath.Join(outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(destination), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(destination, content, fileInfo.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
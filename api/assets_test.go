@@ -0,0 +1,61 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestCollectAssets(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		expect  map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "overlay replaces base",
+			paths: []string{"./base/templates", "./overlay/templates", "./overlay/policy.json"},
+			expect: map[string]string{
+				"templates/user_data.sh":      "../test/assets/overlay/templates/user_data.sh",
+				"templates/nested/config.tpl": "../test/assets/base/templates/nested/config.tpl",
+				"policy.json":                 "../test/assets/overlay/policy.json",
+			},
+		},
+		{
+			name:    "not found",
+			paths:   []string{"./not_found"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := api.NewHCLParser().CollectAssets("../test/assets", tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CollectAssets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestCopyAssets(t *testing.T) {
+	outputDir := t.TempDir()
+	assets := map[string]string{
+		"templates/user_data.sh": "../test/assets/overlay/templates/user_data.sh",
+	}
+
+	if err := api.NewHCLParser().CopyAssets(assets, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "templates/user_data.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "#!/bin/bash\necho \"overlay\"\n", string(content))
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// blockKey identifies a top-level block by its type and its exact label tuple.
+// Labels are quoted before they are joined, so resource "aws_s3" "bucket_logs"
+// and resource "aws_s3_bucket" "logs" never share a key.
+// Provider blocks are also identified by their alias, since a configuration can have
+// several provider "aws" blocks for different regions.
+type blockKey struct {
+	blockType string
+	labels    string
+	alias     string
+	// arguments identifies blocks which have no labels, such as moved blocks, by their addressing arguments.
+	arguments string
+}
+
+// blockIdentityArguments are the arguments which identify blocks without labels.
+var blockIdentityArguments = map[string][]string{
+	"moved":   {"from", "to"},
+	"import":  {"to", "id"},
+	"removed": {"from"},
+}
+
+func newBlockKey(block *hclwrite.Block) blockKey {
+	quoted := make([]string, 0, len(block.Labels()))
+	for _, label := range block.Labels() {
+		quoted = append(quoted, strconv.Quote(label))
+	}
+
+	key := blockKey{
+		blockType: block.Type(),
+		labels:    strings.Join(quoted, " "),
+	}
+	if key.blockType == "provider" || key.blockType == "mock_provider" {
+		key.alias, _ = attributeStringValue(block.Body(), "alias")
+	}
+	if names, ok := blockIdentityArguments[key.blockType]; ok {
+		arguments := make([]string, 0, len(names))
+		for _, name := range names {
+			arguments = append(arguments, name+" = "+attributeSource(block.Body(), name))
+		}
+		key.arguments = strings.Join(arguments, ", ")
+	}
+
+	return key
+}
+
+// String returns the key in the same form as the block header, e.g. `resource "aws_instance" "web"`.
+func (k blockKey) String() string {
+	s := k.blockType
+	if k.labels != "" {
+		s += " " + k.labels
+	}
+	if k.alias != "" {
+		s += " (alias " + strconv.Quote(k.alias) + ")"
+	}
+	if k.arguments != "" {
+		s += " { " + k.arguments + " }"
+	}
+	return s
+}
+
+// blockSet holds blocks by their key in the order they were first added.
+type blockSet struct {
+	keys   []blockKey
+	blocks map[blockKey]*hclwrite.Block
+}
+
+func newBlockSet() *blockSet {
+	return &blockSet{blocks: map[blockKey]*hclwrite.Block{}}
+}
+
+// get is safe to call on a nil set, which simply holds no blocks.
+func (s *blockSet) get(key blockKey) (*hclwrite.Block, bool) {
+	if s == nil {
+		return nil, false
+	}
+	block, ok := s.blocks[key]
+	return block, ok
+}
+
+func (s *blockSet) set(key blockKey, block *hclwrite.Block) {
+	if _, ok := s.blocks[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.blocks[key] = block
+}
+
+// ordered returns the blocks in the order their keys were first added.
+func (s *blockSet) ordered() []*hclwrite.Block {
+	blocks := make([]*hclwrite.Block, 0, len(s.keys))
+	for _, key := range s.keys {
+		blocks = append(blocks, s.blocks[key])
+	}
+	return blocks
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// matchResourceType reports whether the resource type matches any of the glob patterns, e.g. "aws_*".
+func matchResourceType(patterns []string, resourceType string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, resourceType)
+		if err != nil {
+			return false, fmt.Errorf("invalid resource type pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetCommonAttribute merges values into the named attribute, such as tags, of every resource
+// whose type matches any of the resourceTypes glob patterns.
+// An object written in the resource is deep merged with values, and values win on conflicts.
+// Any other expression, e.g. a variable, is merged by wrapping it with the merge function.
+// Resources without the attribute get values as the attribute, unless skipMissing is true.
+// Narrow resourceTypes for resource types which don't support the attribute, e.g. aws_route_table_association.
+func (p HCLParser) SetCommonAttribute(file *hclwrite.File, attribute string, resourceTypes []string, values cty.Value, skipMissing bool) (*hclwrite.File, error) {
+	if !values.Type().IsObjectType() && !values.Type().IsMapType() {
+		return nil, fmt.Errorf("values of the common attribute %q must be an object", attribute)
+	}
+	valueTokens := hclwrite.TokensForValue(values)
+
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "resource" || len(block.Labels()) != 2 {
+			continue
+		}
+		matched, err := matchResourceType(resourceTypes, block.Labels()[0])
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+
+		attr := block.Body().GetAttribute(attribute)
+		if attr == nil {
+			if skipMissing {
+				slog.Info("The resource doesn't have the common attribute, so skip it", "address", strings.Join(block.Labels(), "."), "attribute", attribute)
+				continue
+			}
+			block.Body().SetAttributeRaw(attribute, valueTokens)
+			continue
+		}
+
+		existingTokens := attr.Expr().BuildTokens(nil)
+		if merged, ok := mergeObjectTokens(existingTokens, valueTokens); ok {
+			block.Body().SetAttributeRaw(attribute, merged)
+		} else {
+			block.Body().SetAttributeRaw(attribute, hclwrite.TokensForFunctionCall("merge", existingTokens, valueTokens))
+		}
+	}
+
+	return file, nil
+}
@@ -0,0 +1,161 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSetCommonAttribute(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		attribute     string
+		resourceTypes []string
+		values        cty.Value
+		skipMissing   bool
+		expect        string
+		wantErr       bool
+	}{
+		{
+			name: "tags",
+			content: `resource "aws_instance" "web" {
+  tags = {
+    Name        = "web"
+    Environment = "staging"
+  }
+}
+resource "aws_s3_bucket" "logs" {
+  tags = var.tags
+}
+resource "aws_vpc" "main" {
+}
+resource "random_id" "suffix" {
+}
+data "aws_ami" "ubuntu" {
+}
+`,
+			attribute:     "tags",
+			resourceTypes: []string{"aws_*"},
+			values: cty.ObjectVal(map[string]cty.Value{
+				"Environment": cty.StringVal("production"),
+				"CostCenter":  cty.StringVal("1234"),
+			}),
+			expect: `resource "aws_instance" "web" {
+  tags = {
+    Name        = "web"
+    Environment = "production"
+    CostCenter  = "1234"
+  }
+}
+resource "aws_s3_bucket" "logs" {
+  tags = merge(var.tags, {
+    CostCenter  = "1234"
+    Environment = "production"
+  })
+}
+resource "aws_vpc" "main" {
+  tags = {
+    CostCenter  = "1234"
+    Environment = "production"
+  }
+}
+resource "random_id" "suffix" {
+}
+data "aws_ami" "ubuntu" {
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "skip missing attribute",
+			content: `resource "aws_vpc" "main" {
+  tags = {}
+}
+resource "aws_route_table_association" "public" {
+}
+`,
+			attribute:     "tags",
+			resourceTypes: []string{"aws_*"},
+			values: cty.ObjectVal(map[string]cty.Value{
+				"Environment": cty.StringVal("production"),
+			}),
+			skipMissing: true,
+			expect: `resource "aws_vpc" "main" {
+  tags = {
+    Environment = "production"
+  }
+}
+resource "aws_route_table_association" "public" {
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "labels",
+			content: `resource "google_compute_instance" "web" {
+  labels = {
+    app = "web"
+  }
+}
+`,
+			attribute:     "labels",
+			resourceTypes: []string{"google_compute_instance"},
+			values: cty.MapVal(map[string]cty.Value{
+				"env": cty.StringVal("production"),
+			}),
+			expect: `resource "google_compute_instance" "web" {
+  labels = {
+    app = "web"
+    env = "production"
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "values is not an object",
+			content: `resource "aws_instance" "web" {
+}
+`,
+			attribute:     "tags",
+			resourceTypes: []string{"*"},
+			values:        cty.StringVal("production"),
+			wantErr:       true,
+		},
+		{
+			name: "invalid pattern",
+			content: `resource "aws_instance" "web" {
+}
+`,
+			attribute:     "tags",
+			resourceTypes: []string{"aws_["},
+			values:        cty.EmptyObjectVal,
+			wantErr:       true,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.SetCommonAttribute(file, tt.attribute, tt.resourceTypes, tt.values, tt.skipMissing)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetCommonAttribute() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
@@ -1,13 +1,50 @@
 package api
 
 import (
-	"github.com/hashicorp/hcl/v2/hclsimple"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type TfustomizeConfig struct {
 	Tfustomize Tfustomize `hcl:"tfustomize,block"`
 	Resources  Resource   `hcl:"resources,block"`
 	Patches    Patch      `hcl:"patches,block"`
+	Backend    *Backend   `hcl:"backend,block"`
+	Renames    *Rename    `hcl:"renames,block"`
+
+	CommonAttributes []CommonAttribute `hcl:"common_attributes,block"`
+	TargetPatches    []TargetPatch     `hcl:"patch,block"`
+	Operations       *Operations       `hcl:"operations,block"`
+	Replacements     []Replacement     `hcl:"replacement,block"`
+	Versions         *Versions         `hcl:"versions,block"`
+	LocalsGenerators []LocalsGenerator `hcl:"locals_generator,block"`
+	VariableDefaults *VariableDefaults `hcl:"variable_defaults,block"`
+	Assets           *Assets           `hcl:"assets,block"`
+
+	// NamePrefix and NameSuffix are added to the names of every resource, data and module block.
+	NamePrefix string `hcl:"name_prefix,optional"`
+	NameSuffix string `hcl:"name_suffix,optional"`
+	// NameAttributes are attributes of resource blocks which also get NamePrefix and NameSuffix, e.g. "name".
+	NameAttributes []string `hcl:"name_attributes,optional"`
+
+	// Placeholders are the values of placeholders such as `{{ .env }}` in string literals of the base files.
+	Placeholders map[string]string `hcl:"placeholders,optional"`
+
+	// Vars are the variables referred to as `var.<name>` in tfustomization.hcl.
+	Vars []Var `hcl:"var,block"`
+}
+
+// Var is a block of variables such as `region = "us-east-1"`.
+type Var struct {
+	Values hcl.Attributes `hcl:",remain"`
 }
 
 type Tfustomize struct {
@@ -19,17 +56,349 @@ type Resource struct {
 }
 
 type Patch struct {
+	Paths  []string `hcl:"paths,optional"`
+	Remain hcl.Body `hcl:",remain"`
+	// Inline are the blocks written as `patch "<block type>" "<labels>"...` in the patches block.
+	Inline []*hclwrite.Block
+}
+
+// Assets are files such as templates and scripts copied into the output directory.
+type Assets struct {
 	Paths []string `hcl:"paths,attr"`
 }
 
-func LoadConfig(configPath string) (TfustomizeConfig, error) {
-	return decodeConfigFromFile(configPath)
+// Rename maps addresses of blocks to their new addresses, e.g. "aws_instance.old" = "aws_instance.new".
+type Rename struct {
+	Addresses map[string]string `hcl:"addresses,attr"`
+}
+
+// Operations are JSON-Patch like operations applied in order.
+type Operations struct {
+	Ops []Operation `hcl:"op,block"`
+}
+
+// Operation is one of add, remove, replace, move, copy and test, acting on a path such as
+// "resource.aws_instance.web.ebs_block_device[1].volume_size".
+type Operation struct {
+	Op     string   `hcl:"op,label"`
+	Path   string   `hcl:"path,attr"`
+	From   string   `hcl:"from,optional"`
+	Remain hcl.Body `hcl:",remain"`
+	// Value is the value expression as written in tfustomization.hcl.
+	Value hclwrite.Tokens
+}
+
+// Replacement writes the value at the source path, or the value expression, into the attributes at the target paths.
+type Replacement struct {
+	Source string `hcl:"source,optional"`
+	// Targets are paths whose labels can be glob patterns, e.g. "module.*.version".
+	Targets []string `hcl:"targets,attr"`
+	Remain  hcl.Body `hcl:",remain"`
+	// Value is the value expression as written in tfustomization.hcl.
+	Value hclwrite.Tokens
+}
+
+// Versions pin the versions of modules and providers.
+type Versions struct {
+	Modules   []ModuleVersion   `hcl:"module,block"`
+	Providers []ProviderVersion `hcl:"provider,block"`
+}
+
+// ModuleVersion is the version of module blocks whose source matches the glob pattern.
+type ModuleVersion struct {
+	Source  string `hcl:"source,attr"`
+	Version string `hcl:"version,attr"`
+}
+
+// ProviderVersion is the version constraint of a provider in required_providers.
+type ProviderVersion struct {
+	Name    string `hcl:"name,attr"`
+	Version string `hcl:"version,attr"`
+}
+
+// LocalsGenerator generates local values from a JSON, YAML or CSV file.
+type LocalsGenerator struct {
+	// Path is the data file relative to tfustomization.hcl.
+	Path string `hcl:"path,attr"`
+	// Name is the name of the local value. Without it, every item of the top-level object is a local value.
+	Name string `hcl:"name,optional"`
+}
+
+// VariableDefaults override the defaults of variable blocks.
+type VariableDefaults struct {
+	Values cty.Value `hcl:"values,optional"`
+	// Tfvars is a .tfvars file relative to tfustomization.hcl. Values take precedence over it.
+	Tfvars string `hcl:"tfvars,optional"`
+}
+
+// Tokens returns the defaults keyed by variable names. baseDir is the directory of tfustomization.hcl.
+func (d VariableDefaults) Tokens(baseDir string) (map[string]hclwrite.Tokens, error) {
+	defaults := map[string]hclwrite.Tokens{}
+
+	if d.Tfvars != "" {
+		file, err := NewHCLParser().ReadHCLFile(filepath.Join(baseDir, d.Tfvars))
+		if err != nil {
+			return nil, err
+		}
+		for name, attr := range file.Body().Attributes() {
+			defaults[name] = attr.Expr().BuildTokens(nil)
+		}
+	}
+
+	if !d.Values.IsNull() {
+		if !d.Values.Type().IsObjectType() && !d.Values.Type().IsMapType() {
+			return nil, fmt.Errorf("values of variable_defaults must be an object")
+		}
+		for name, value := range d.Values.AsValueMap() {
+			defaults[name] = hclwrite.TokensForValue(value)
+		}
+	}
+
+	return defaults, nil
+}
+
+// CommonAttribute is an object, such as common tags, merged into an attribute of every matching resource.
+type CommonAttribute struct {
+	// Attribute is the name of the attribute. It's "tags" by default.
+	Attribute string `hcl:"attribute,optional"`
+	// ResourceTypes are glob patterns of resource types, e.g. "aws_*".
+	ResourceTypes []string  `hcl:"resource_types,attr"`
+	Values        cty.Value `hcl:"values,attr"`
+	// SkipMissing leaves matching resources which don't have the attribute as they are.
+	SkipMissing bool `hcl:"skip_missing,optional"`
+}
+
+// AttributeName returns the name of the attribute, which is "tags" by default.
+func (c CommonAttribute) AttributeName() string {
+	if c.Attribute == "" {
+		return "tags"
+	}
+	return c.Attribute
+}
+
+// TargetPatch is a patch applied to every block selected by the target.
+type TargetPatch struct {
+	Target Target   `hcl:"target,block"`
+	Remain hcl.Body `hcl:",remain"`
+	// Patch is the patch body as written in tfustomization.hcl, without the target block.
+	Patch *hclwrite.Block
+}
+
+// Target selects top-level blocks. A block is selected when it matches all the given conditions.
+type Target struct {
+	// BlockType is the type of blocks. It's "resource" by default.
+	BlockType string `hcl:"block_type,optional"`
+	// Address is a glob pattern of the labels joined by dots, e.g. "aws_instance.*".
+	// The alias of a provider is joined too, e.g. "aws.west".
+	Address string `hcl:"address,optional"`
+	// LabelRegex is a regular expression of the labels joined by dots.
+	LabelRegex string `hcl:"label_regex,optional"`
+	// Annotation is a label annotated with `# tfustomize:label:<label>` in the block.
+	Annotation string `hcl:"annotation,optional"`
+}
+
+// Backend is the backend configuration of an environment, which is injected into the terraform block.
+type Backend struct {
+	Type   string   `hcl:"type,label"`
+	Config hcl.Body `hcl:",remain"`
+
+	ctx *hcl.EvalContext
+}
+
+// BuildBlock evaluates the backend configuration and returns it as a backend block.
+func (b Backend) BuildBlock() (*hclwrite.Block, error) {
+	block := hclwrite.NewBlock("backend", []string{b.Type})
+
+	body, ok := b.Config.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("backend %q must be written in HCL native syntax", b.Type)
+	}
+	diags := writeBodyValues(block.Body(), body, b.ctx)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+
+	return block, nil
 }
 
-func decodeConfigFromFile(path string) (tfusconf TfustomizeConfig, err error) {
-	err = hclsimple.DecodeFile(path, nil, &tfusconf)
+// writeBodyValues evaluates attributes of body and writes their values into target.
+// Nested blocks are written recursively.
+func writeBodyValues(target *hclwrite.Body, body *hclsyntax.Body, ctx *hcl.EvalContext) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	attributes := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attribute := range body.Attributes {
+		attributes = append(attributes, attribute)
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].SrcRange.Start.Byte < attributes[j].SrcRange.Start.Byte
+	})
+
+	for _, attribute := range attributes {
+		value, valueDiags := attribute.Expr.Value(ctx)
+		diags = append(diags, valueDiags...)
+		if valueDiags.HasErrors() {
+			continue
+		}
+		target.SetAttributeValue(attribute.Name, value)
+	}
+
+	for _, block := range body.Blocks {
+		nestedBlock := target.AppendNewBlock(block.Type, block.Labels)
+		diags = append(diags, writeBodyValues(nestedBlock.Body(), block.Body, ctx)...)
+	}
+
+	return diags
+}
+
+// LoadConfig loads tfustomization.hcl. vars are the values of variables given on the command line,
+// which take precedence over the var blocks.
+func LoadConfig(configPath string, vars map[string]string) (TfustomizeConfig, error) {
+	return decodeConfigFromFile(configPath, vars)
+}
+
+func decodeConfigFromFile(path string, vars map[string]string) (tfusconf TfustomizeConfig, err error) {
+	src, err := os.ReadFile(path)
 	if err != nil {
 		return
 	}
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return tfusconf, fmt.Errorf(diags.Error())
+	}
+
+	ctx, err := configEvalContext(file.Body, vars)
+	if err != nil {
+		return
+	}
+	diags = gohcl.DecodeBody(file.Body, ctx, &tfusconf)
+	if diags.HasErrors() {
+		return tfusconf, fmt.Errorf(diags.Error())
+	}
+	if tfusconf.Backend != nil {
+		tfusconf.Backend.ctx = ctx
+	}
+	if tfusconf.Patches.Remain != nil {
+		// The remaining body must have only patch blocks, which are checked by inlinePatchBlocks.
+		// Diagnostics about the blocks are ignored, since JustAttributes doesn't expect them.
+		attributes, _ := tfusconf.Patches.Remain.JustAttributes()
+		for name := range attributes {
+			return tfusconf, fmt.Errorf("unsupported attribute %q in the patches block", name)
+		}
+	}
+
+	err = loadRawBlocks(path, &tfusconf)
 	return
 }
+
+// configEvalContext evaluates the var blocks in body and returns the context to decode tfustomization.hcl.
+// Variables can use functions, but not other variables.
+func configEvalContext(body hcl.Body, vars map[string]string) (*hcl.EvalContext, error) {
+	ctx := &hcl.EvalContext{Functions: configFunctions()}
+
+	content, _, diags := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "var"}},
+	})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+
+	values := map[string]cty.Value{}
+	for _, block := range content.Blocks {
+		attributes, diags := block.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, fmt.Errorf(diags.Error())
+		}
+		for name, attribute := range attributes {
+			value, diags := attribute.Expr.Value(ctx)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf(diags.Error())
+			}
+			values[name] = value
+		}
+	}
+	for name, value := range vars {
+		values[name] = cty.StringVal(value)
+	}
+
+	ctx.Variables = map[string]cty.Value{"var": cty.ObjectVal(values)}
+	return ctx, nil
+}
+
+// loadRawBlocks sets the blocks of tfustomization.hcl which are copied into the result as written,
+// because their expressions are evaluated by Terraform instead of tfustomize.
+func loadRawBlocks(path string, tfusconf *TfustomizeConfig) error {
+	file, err := NewHCLParser().ReadHCLFile(path)
+	if err != nil {
+		return err
+	}
+
+	patchIndex := 0
+	replacementIndex := 0
+	for _, block := range file.Body().Blocks() {
+		if block.Type() == "replacement" && replacementIndex < len(tfusconf.Replacements) {
+			if attr := block.Body().GetAttribute("value"); attr != nil {
+				tfusconf.Replacements[replacementIndex].Value = attr.Expr().BuildTokens(nil)
+			}
+			replacementIndex++
+			continue
+		}
+
+		if block.Type() == "operations" && tfusconf.Operations != nil {
+			for i, opBlock := range block.Body().Blocks() {
+				if i >= len(tfusconf.Operations.Ops) {
+					break
+				}
+				if attr := opBlock.Body().GetAttribute("value"); attr != nil {
+					tfusconf.Operations.Ops[i].Value = attr.Expr().BuildTokens(nil)
+				}
+			}
+			continue
+		}
+
+		if block.Type() == "patches" {
+			inline, err := inlinePatchBlocks(block)
+			if err != nil {
+				return err
+			}
+			tfusconf.Patches.Inline = append(tfusconf.Patches.Inline, inline...)
+			continue
+		}
+
+		if block.Type() != "patch" || patchIndex >= len(tfusconf.TargetPatches) {
+			continue
+		}
+		for _, nestedBlock := range block.Body().Blocks() {
+			if nestedBlock.Type() == "target" {
+				block.Body().RemoveBlock(nestedBlock)
+			}
+		}
+		tfusconf.TargetPatches[patchIndex].Patch = block
+		patchIndex++
+	}
+
+	return nil
+}
+
+// inlinePatchBlocks converts `patch "resource" "aws_instance" "web" { ... }` blocks in the patches block
+// into `resource "aws_instance" "web" { ... }` blocks.
+func inlinePatchBlocks(patches *hclwrite.Block) ([]*hclwrite.Block, error) {
+	var blocks []*hclwrite.Block
+	for _, block := range patches.Body().Blocks() {
+		if block.Type() != "patch" {
+			return nil, fmt.Errorf("unsupported block type %q in the patches block", block.Type())
+		}
+		labels := block.Labels()
+		if len(labels) == 0 {
+			return nil, fmt.Errorf("a patch block in the patches block must have the block type as its first label")
+		}
+
+		// Build a new block, since hclwrite.Block.SetType does not update Type().
+		inlineBlock, err := newBlockFromBody(labels[0], labels[1:], block.Body())
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, inlineBlock)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// envFunc returns the value of an environment variable, or the default value when it's not set.
+// The default value is optional, so it's declared as VarParam and extra arguments are rejected.
+var envFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "name", Type: cty.String},
+	},
+	VarParam: &function.Parameter{Name: "default", Type: cty.String},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		if len(args) > 2 {
+			return cty.NilType, function.NewArgErrorf(2, "env takes a name and an optional default value, but %d arguments are given", len(args))
+		}
+		return cty.String, nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		name := args[0].AsString()
+		if value, ok := os.LookupEnv(name); ok {
+			return cty.StringVal(value), nil
+		}
+		if len(args) > 1 {
+			return args[1], nil
+		}
+		return cty.NilVal, fmt.Errorf("environment variable %q is not set", name)
+	},
+})
+
+// configFunctions returns the functions available in tfustomization.hcl.
+func configFunctions() map[string]function.Function {
+	return map[string]function.Function{
+		"env": envFunc,
+
+		"chomp":      stdlib.ChompFunc,
+		"format":     stdlib.FormatFunc,
+		"formatlist": stdlib.FormatListFunc,
+		"indent":     stdlib.IndentFunc,
+		"join":       stdlib.JoinFunc,
+		"lower":      stdlib.LowerFunc,
+		"regex":      stdlib.RegexFunc,
+		"regexall":   stdlib.RegexAllFunc,
+		"replace":    stdlib.ReplaceFunc,
+		"split":      stdlib.SplitFunc,
+		"strlen":     stdlib.StrlenFunc,
+		"substr":     stdlib.SubstrFunc,
+		"title":      stdlib.TitleFunc,
+		"trim":       stdlib.TrimFunc,
+		"trimprefix": stdlib.TrimPrefixFunc,
+		"trimspace":  stdlib.TrimSpaceFunc,
+		"trimsuffix": stdlib.TrimSuffixFunc,
+		"upper":      stdlib.UpperFunc,
+
+		"chunklist": stdlib.ChunklistFunc,
+		"coalesce":  stdlib.CoalesceFunc,
+		"compact":   stdlib.CompactFunc,
+		"concat":    stdlib.ConcatFunc,
+		"contains":  stdlib.ContainsFunc,
+		"distinct":  stdlib.DistinctFunc,
+		"element":   stdlib.ElementFunc,
+		"flatten":   stdlib.FlattenFunc,
+		"keys":      stdlib.KeysFunc,
+		"length":    stdlib.LengthFunc,
+		"lookup":    stdlib.LookupFunc,
+		"merge":     stdlib.MergeFunc,
+		"range":     stdlib.RangeFunc,
+		"reverse":   stdlib.ReverseListFunc,
+		"slice":     stdlib.SliceFunc,
+		"sort":      stdlib.SortFunc,
+		"values":    stdlib.ValuesFunc,
+		"zipmap":    stdlib.ZipmapFunc,
+
+		"jsondecode": stdlib.JSONDecodeFunc,
+		"jsonencode": stdlib.JSONEncodeFunc,
+		"max":        stdlib.MaxFunc,
+		"min":        stdlib.MinFunc,
+	}
+}
@@ -1,8 +1,11 @@
 package api_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
 	"github.com/tk3fftk/tfustomize/api"
 )
 
@@ -22,14 +25,135 @@ func TestLoadConfig(t *testing.T) {
 			config:  "../test/invalid/broken_schema_tfustomization.hcl",
 			wantErr: true,
 		},
+		{
+			name:    "env with extra arguments",
+			config:  "../test/invalid_cases/env_arguments_tfustomization.hcl",
+			wantErr: true,
+		},
+		{
+			name:    "unknown attribute in the patches block",
+			config:  "../test/invalid_cases/unknown_patches_attribute_tfustomization.hcl",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := api.LoadConfig(tt.config)
+			_, err := api.LoadConfig(tt.config, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestBackendBuildBlock(t *testing.T) {
+	conf, err := api.LoadConfig("../test/backend/tfustomization.hcl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Backend == nil {
+		t.Fatal("backend is not loaded")
+	}
+
+	block, err := conf.Backend.BuildBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := hclwrite.NewEmptyFile()
+	file.Body().AppendBlock(block)
+	assert.Equal(t, `backend "s3" {
+  bucket = "tfstate-production"
+  key    = "app/terraform.tfstate"
+  region = "ap-northeast-1"
+  assume_role {
+    role_arn = "arn:aws:iam::123456789012:role/terraform"
+  }
+}
+`, string(hclwrite.Format(file.Bytes())))
+}
+
+func TestLoadConfigTransformers(t *testing.T) {
+	conf, err := api.LoadConfig("../test/transformers/tfustomization.hcl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]string{"aws_instance.web": "aws_instance.app"}, conf.Renames.Addresses)
+	assert.Equal(t, "prod_", conf.NamePrefix)
+	assert.Equal(t, []string{"name"}, conf.NameAttributes)
+	assert.Equal(t, map[string]string{"env": "production"}, conf.Placeholders)
+	assert.Len(t, conf.CommonAttributes, 1)
+	assert.Equal(t, "tags", conf.CommonAttributes[0].AttributeName())
+	assert.Equal(t, []string{"aws_*"}, conf.CommonAttributes[0].ResourceTypes)
+	assert.Len(t, conf.TargetPatches, 1)
+	assert.Equal(t, "aws_instance.*", conf.TargetPatches[0].Target.Address)
+	assert.NotNil(t, conf.TargetPatches[0].Patch.Body().GetAttribute("monitoring"))
+	assert.Empty(t, conf.TargetPatches[0].Patch.Body().Blocks())
+	assert.Len(t, conf.Operations.Ops, 2)
+	assert.Equal(t, "replace", conf.Operations.Ops[1].Op)
+	assert.Equal(t, "resource.aws_instance.web.instance_type", conf.Operations.Ops[1].Path)
+	assert.Equal(t, ` "m5.large"`, string(conf.Operations.Ops[1].Value.Bytes()))
+	assert.Equal(t, []api.ModuleVersion{{Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0"}}, conf.Versions.Modules)
+	assert.Equal(t, []api.ProviderVersion{{Name: "aws", Version: "~> 5.0"}}, conf.Versions.Providers)
+	assert.Len(t, conf.Replacements, 2)
+	assert.Equal(t, "locals.ami", conf.Replacements[0].Source)
+	assert.Nil(t, conf.Replacements[0].Value)
+	assert.Equal(t, []string{"resource.aws_instance.*.instance_type"}, conf.Replacements[1].Targets)
+	assert.Equal(t, ` "m5.large"`, string(conf.Replacements[1].Value.Bytes()))
+}
+
+func TestLoadConfigInlinePatches(t *testing.T) {
+	conf, err := api.LoadConfig("../test/inline_patches/tfustomization.hcl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"../overlay/data_and_resource.tf"}, conf.Patches.Paths)
+	assert.Len(t, conf.Patches.Inline, 2)
+	assert.Equal(t, "resource", conf.Patches.Inline[0].Type())
+	assert.Equal(t, []string{"aws_instance", "web"}, conf.Patches.Inline[0].Labels())
+	assert.NotNil(t, conf.Patches.Inline[0].Body().GetAttribute("instance_type"))
+	assert.Equal(t, "locals", conf.Patches.Inline[1].Type())
+	assert.Empty(t, conf.Patches.Inline[1].Labels())
+}
+
+func TestLoadConfigVariables(t *testing.T) {
+	tests := []struct {
+		name       string
+		vars       map[string]string
+		wantPaths  []string
+		wantBucket string
+	}{
+		{
+			name:       "var block",
+			vars:       nil,
+			wantPaths:  []string{"../base/staging.tf"},
+			wantBucket: `"tfstate-staging"`,
+		},
+		{
+			name:       "command line vars take precedence",
+			vars:       map[string]string{"env": "production"},
+			wantPaths:  []string{"../base/production.tf"},
+			wantBucket: `"tfstate-production"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := api.LoadConfig("../test/variables/tfustomization.hcl", tt.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantPaths, conf.Resources.Paths)
+
+			block, err := conf.Backend.BuildBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantBucket, strings.TrimSpace(string(block.Body().GetAttribute("bucket").Expr().BuildTokens(nil).Bytes())))
+			assert.Equal(t, `"ap-northeast-1"`, strings.TrimSpace(string(block.Body().GetAttribute("region").Expr().BuildTokens(nil).Bytes())))
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// tokensFromSource lexes src as a single expression so that it can be set to an attribute.
+func tokensFromSource(src []byte) (hclwrite.Tokens, error) {
+	file, diags := hclwrite.ParseConfig(append([]byte("expr = "), src...), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+
+	attr := file.Body().GetAttribute("expr")
+	if attr == nil {
+		return nil, fmt.Errorf("%q is not a single expression", src)
+	}
+
+	return attr.Expr().BuildTokens(nil), nil
+}
+
+// objectItem is an item of an object constructor expression such as `{ key = value }`.
+type objectItem struct {
+	key      string
+	keySrc   []byte
+	valueSrc []byte
+}
+
+// objectItems returns the items of src when src is an object constructor expression.
+// A key is compared by its string value, so `aws` and `"aws"` are the same key.
+func objectItems(src []byte) ([]objectItem, bool) {
+	expr, diags := hclsyntax.ParseExpression(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, false
+	}
+	obj, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil, false
+	}
+
+	items := make([]objectItem, 0, len(obj.Items))
+	for _, item := range obj.Items {
+		keyRange := item.KeyExpr.Range()
+		valueRange := item.ValueExpr.Range()
+		keySrc := src[keyRange.Start.Byte:keyRange.End.Byte]
+
+		key := string(keySrc)
+		if value, diags := item.KeyExpr.Value(nil); !diags.HasErrors() && value.IsKnown() && value.Type() == cty.String {
+			key = value.AsString()
+		}
+
+		items = append(items, objectItem{
+			key:      key,
+			keySrc:   keySrc,
+			valueSrc: src[valueRange.Start.Byte:valueRange.End.Byte],
+		})
+	}
+
+	return items, true
+}
+
+// mergeObjectSource deep merges two object constructor expressions.
+// Items of the overlay replace items of the base with the same key, unless both of them are objects,
+// in which case they are merged recursively. It returns false when either of them is not an object.
+func mergeObjectSource(base []byte, overlay []byte) ([]byte, bool) {
+	baseItems, ok := objectItems(base)
+	if !ok {
+		return nil, false
+	}
+	overlayItems, ok := objectItems(overlay)
+	if !ok {
+		return nil, false
+	}
+
+	overlayByKey := map[string]objectItem{}
+	for _, item := range overlayItems {
+		overlayByKey[item.key] = item
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	writeItem := func(keySrc []byte, valueSrc []byte) {
+		buf.Write(keySrc)
+		buf.WriteString(" = ")
+		buf.Write(valueSrc)
+		buf.WriteString("\n")
+	}
+
+	seen := map[string]bool{}
+	for _, baseItem := range baseItems {
+		seen[baseItem.key] = true
+		overlayItem, ok := overlayByKey[baseItem.key]
+		if !ok {
+			writeItem(baseItem.keySrc, baseItem.valueSrc)
+			continue
+		}
+		if merged, ok := mergeObjectSource(baseItem.valueSrc, overlayItem.valueSrc); ok {
+			writeItem(baseItem.keySrc, merged)
+		} else {
+			writeItem(baseItem.keySrc, overlayItem.valueSrc)
+		}
+	}
+	for _, overlayItem := range overlayItems {
+		if !seen[overlayItem.key] {
+			writeItem(overlayItem.keySrc, overlayItem.valueSrc)
+		}
+	}
+	buf.WriteString("}")
+
+	return buf.Bytes(), true
+}
+
+// mergeObjectTokens deep merges two attribute expressions with mergeObjectSource.
+func mergeObjectTokens(base hclwrite.Tokens, overlay hclwrite.Tokens) (hclwrite.Tokens, bool) {
+	merged, ok := mergeObjectSource(bytes.TrimSpace(base.Bytes()), bytes.TrimSpace(overlay.Bytes()))
+	if !ok {
+		return nil, false
+	}
+
+	tokens, err := tokensFromSource(merged)
+	if err != nil {
+		return nil, false
+	}
+
+	return tokens, true
+}
+
+// unionListTokens returns a tuple constructor expression which has the elements of the base
+// followed by the elements of the overlay which are not in the base.
+// It returns false when either of them is not a tuple constructor expression.
+func unionListTokens(base hclwrite.Tokens, overlay hclwrite.Tokens) (hclwrite.Tokens, bool) {
+	var elems [][]byte
+	seen := map[string]bool{}
+
+	for _, tokens := range []hclwrite.Tokens{base, overlay} {
+		src := bytes.TrimSpace(tokens.Bytes())
+		expr, diags := hclsyntax.ParseExpression(src, "", hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, false
+		}
+		tuple, ok := expr.(*hclsyntax.TupleConsExpr)
+		if !ok {
+			return nil, false
+		}
+		for _, elem := range tuple.Exprs {
+			elemSrc := src[elem.Range().Start.Byte:elem.Range().End.Byte]
+			if seen[string(elemSrc)] {
+				continue
+			}
+			seen[string(elemSrc)] = true
+			elems = append(elems, elemSrc)
+		}
+	}
+
+	merged, err := tokensFromSource(append(append([]byte("["), bytes.Join(elems, []byte(", "))...), ']'))
+	if err != nil {
+		return nil, false
+	}
+
+	return merged, true
+}
+
+// baseReference is the placeholder which refers to the base expression in an overlay expression,
+// e.g. `concat(tfustomize_base, ["sg-prod"])`.
+const baseReference = "tfustomize_base"
+
+// isBaseReference reports whether the token at i is the placeholder, not an attribute such as `foo.tfustomize_base`.
+func isBaseReference(tokens hclwrite.Tokens, i int) bool {
+	if tokens[i].Type != hclsyntax.TokenIdent || string(tokens[i].Bytes) != baseReference {
+		return false
+	}
+	return i == 0 || tokens[i-1].Type != hclsyntax.TokenDot
+}
+
+func hasBaseReference(tokens hclwrite.Tokens) bool {
+	for i := range tokens {
+		if isBaseReference(tokens, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNoBaseReference returns an error when the block, which has no base block to be merged into, refers to the base value.
+func checkNoBaseReference(block *hclwrite.Block) error {
+	if hasBaseReference(block.Body().BuildTokens(nil)) {
+		return fmt.Errorf("%s: %s is used but there is no base value", newBlockKey(block), baseReference)
+	}
+	return nil
+}
+
+// substituteBase replaces the placeholder in the overlay expression with the base expression.
+// The base expression is parenthesized when it's an operation, so that `tfustomize_base * 2` keeps its meaning.
+func substituteBase(base hclwrite.Tokens, overlay hclwrite.Tokens) (hclwrite.Tokens, error) {
+	if !hasBaseReference(overlay) {
+		return overlay, nil
+	}
+	if base == nil {
+		return nil, fmt.Errorf("%s is used but there is no base value", baseReference)
+	}
+
+	replacement := hclwrite.Tokens{}
+	for _, token := range base {
+		copied := *token
+		replacement = append(replacement, &copied)
+	}
+	if len(replacement) > 0 {
+		replacement[0].SpacesBefore = 0
+	}
+	if expr, diags := hclsyntax.ParseExpression(bytes.TrimSpace(base.Bytes()), "", hcl.InitialPos); !diags.HasErrors() {
+		switch expr.(type) {
+		case *hclsyntax.BinaryOpExpr, *hclsyntax.ConditionalExpr, *hclsyntax.UnaryOpExpr:
+			replacement = append(hclwrite.Tokens{{Type: hclsyntax.TokenOParen, Bytes: []byte("(")}}, replacement...)
+			replacement = append(replacement, &hclwrite.Token{Type: hclsyntax.TokenCParen, Bytes: []byte(")")})
+		}
+	}
+
+	result := hclwrite.Tokens{}
+	for i, token := range overlay {
+		if !isBaseReference(overlay, i) {
+			result = append(result, token)
+			continue
+		}
+		for j, replacementToken := range replacement {
+			copied := *replacementToken
+			if j == 0 {
+				copied.SpacesBefore = token.SpacesBefore
+			}
+			result = append(result, &copied)
+		}
+	}
+
+	return result, nil
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
 	"log/slog"
 	"os"
@@ -12,7 +13,9 @@ import (
 	"golang.org/x/exp/slices"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
 )
 
 var tfUniqueBlockTypes = []string{
@@ -34,12 +37,24 @@ var tfNoLabelBlockTypes = []string{
 var annotationBlockMergeRegexp = regexp.MustCompile(`tfustomize:merge_block:([\w]+)`)
 
 type HCLParser struct {
+	// OutputDir is the directory where the result is written. When it's set, relative paths
+	// in the files are rewritten to be relative to it.
+	OutputDir string
+	// Assets are files copied into OutputDir, keyed by their paths relative to it.
+	Assets map[string]string
+
+	// labelAnnotations keeps label annotations of merged blocks, which aren't written into the result,
+	// so that patch targets can still select them.
+	labelAnnotations map[blockKey][]string
 }
 
 func NewHCLParser() *HCLParser {
-	return &HCLParser{}
+	return &HCLParser{
+		labelAnnotations: map[blockKey][]string{},
+	}
 }
 
+// ReadHCLFile reads a configuration file. A file in JSON syntax, such as a .tf.json file, is converted into native syntax.
 func (p HCLParser) ReadHCLFile(filename string) (*hclwrite.File, error) {
 	output := hclwrite.NewEmptyFile()
 
@@ -47,6 +62,12 @@ func (p HCLParser) ReadHCLFile(filename string) (*hclwrite.File, error) {
 	if err != nil {
 		return output, err
 	}
+	if isJSONConfigFile(filename) {
+		src, err = jsonConfigToHCL(src, filename)
+		if err != nil {
+			return output, err
+		}
+	}
 
 	file, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
 	if diags.HasErrors() {
@@ -56,11 +77,42 @@ func (p HCLParser) ReadHCLFile(filename string) (*hclwrite.File, error) {
 	return file, nil
 }
 
-// CollectHCLFilePaths returns a list of .if files in the given paths.
-// If a path is a directory, it returns all .if files in the directory.
-// If a path is a file, it returns the file if it has a .tf extension.
+// CollectHCLFilePaths returns a list of .tf, .tofu, .tf.json and .tofu.json files in the given paths.
+// If a path is a directory, it returns all of these files in the directory.
+// A .tofu file shadows the .tf file of the same name in the directory, as OpenTofu does, and so does
+// a .tofu.json file for the .tf.json file.
+// If a path is a file, it returns the file if it has one of these extensions.
 // The baseDir parameter is used as the root directory when constructing the full path of each file.
 func (p HCLParser) CollectHCLFilePaths(baseDir string, paths []string) ([]string, error) {
+	return collectFilePaths(baseDir, paths, isConfigFile)
+}
+
+// CollectTfvarsFilePaths returns a list of .tfvars and .tfvars.json files in the given paths
+// in the same way as CollectHCLFilePaths.
+func (p HCLParser) CollectTfvarsFilePaths(baseDir string, paths []string) ([]string, error) {
+	return collectFilePaths(baseDir, paths, isTfvarsFile)
+}
+
+func isHCLFile(name string) bool {
+	return filepath.Ext(name) == ".tf" || filepath.Ext(name) == ".tofu"
+}
+
+func isTfvarsFile(name string) bool {
+	return strings.HasSuffix(name, ".tfvars") || strings.HasSuffix(name, ".tfvars.json")
+}
+
+// isJSONConfigFile reports whether the file is a configuration in JSON syntax.
+func isJSONConfigFile(name string) bool {
+	return strings.HasSuffix(name, ".tf.json") || strings.HasSuffix(name, ".tofu.json")
+}
+
+func isConfigFile(name string) bool {
+	return isHCLFile(name) || isJSONConfigFile(name)
+}
+
+// collectFilePaths returns the files in the given paths which match.
+// A file given explicitly which is not supported at all is warned and ignored.
+func collectFilePaths(baseDir string, paths []string, match func(name string) bool) ([]string, error) {
 	var collectedPaths []string
 
 	for _, path := range paths {
@@ -76,23 +128,56 @@ func (p HCLParser) CollectHCLFilePaths(baseDir string, paths []string) ([]string
 				return nil, err
 			}
 			for _, fileInfo := range fileInfos {
-				if filepath.Ext(fileInfo.Name()) == ".tf" {
-					collectedPaths = append(collectedPaths, filepath.Join(fullPath, fileInfo.Name()))
+				name := fileInfo.Name()
+				if match(name) {
+					collectedPaths = append(collectedPaths, filepath.Join(fullPath, name))
 				}
 			}
 		} else {
-			if filepath.Ext(fileInfo.Name()) == ".tf" {
+			if match(fileInfo.Name()) {
 				collectedPaths = append(collectedPaths, fullPath)
-			} else {
-				slog.Warn("Only .tf file extension is supported, so ignore the file", "filename", fileInfo.Name())
+			} else if !isConfigFile(fileInfo.Name()) && !isTfvarsFile(fileInfo.Name()) {
+				slog.Warn("Only .tf, .tofu, .tf.json, .tofu.json, .tfvars and .tfvars.json file extensions are supported, so ignore the file", "filename", fileInfo.Name())
 			}
 		}
 	}
 
-	return collectedPaths, nil
+	return removeShadowedFiles(collectedPaths), nil
 }
 
-// ConcatFiles concatenates the contents of the given .tf files.
+// removeShadowedFiles removes .tf and .tf.json files which have a .tofu or .tofu.json file of the same name
+// in the same directory, as OpenTofu does. It applies to files given explicitly too, so that they aren't
+// concatenated twice.
+func removeShadowedFiles(paths []string) []string {
+	collected := map[string]bool{}
+	for _, path := range paths {
+		collected[path] = true
+	}
+
+	var result []string
+	for _, path := range paths {
+		if tofuPath, ok := tofuAlternativePath(path); ok && collected[tofuPath] {
+			slog.Debug("The file is shadowed by the OpenTofu file", "filename", path, "shadowed_by", tofuPath)
+			continue
+		}
+		result = append(result, path)
+	}
+	return result
+}
+
+// tofuAlternativePath returns the path of the .tofu or .tofu.json file which shadows a .tf or .tf.json file.
+func tofuAlternativePath(path string) (string, bool) {
+	switch {
+	case strings.HasSuffix(path, ".tf"):
+		return strings.TrimSuffix(path, ".tf") + ".tofu", true
+	case strings.HasSuffix(path, ".tf.json"):
+		return strings.TrimSuffix(path, ".tf.json") + ".tofu.json", true
+	}
+	return "", false
+}
+
+// ConcatFiles concatenates the contents of the given configuration files.
+// When OutputDir is set, relative paths in each file are rewritten to be relative to OutputDir.
 func (p HCLParser) ConcatFiles(paths []string) (*hclwrite.File, error) {
 	outputFile := hclwrite.NewEmptyFile()
 
@@ -101,6 +186,11 @@ func (p HCLParser) ConcatFiles(paths []string) (*hclwrite.File, error) {
 		if err != nil {
 			return nil, err
 		}
+		if p.OutputDir != "" {
+			if err := p.rewriteRelativePaths(file, filepath.Dir(path)); err != nil {
+				return nil, err
+			}
+		}
 		for _, block := range file.Body().Blocks() {
 			outputFile.Body().AppendBlock(block)
 		}
@@ -109,16 +199,180 @@ func (p HCLParser) ConcatFiles(paths []string) (*hclwrite.File, error) {
 	return outputFile, nil
 }
 
-func setBodyAttribute(target *hclwrite.Body, name string, attr *hclwrite.Attribute) *hclwrite.Body {
-	tokens := attr.Expr().BuildTokens(nil)
+// ConcatBlocks returns a file of the given blocks written in a file in sourceDir, such as inline patch blocks
+// in tfustomization.hcl. When OutputDir is set, relative paths are rewritten in the same way as ConcatFiles.
+func (p HCLParser) ConcatBlocks(blocks []*hclwrite.Block, sourceDir string) (*hclwrite.File, error) {
+	outputFile := hclwrite.NewEmptyFile()
+	for _, block := range blocks {
+		outputFile.Body().AppendBlock(block)
+	}
+
+	if p.OutputDir != "" {
+		if err := p.rewriteRelativePaths(outputFile, sourceDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return outputFile, nil
+}
+
+func setBodyAttribute(target *hclwrite.Body, name string, tokens hclwrite.Tokens) *hclwrite.Body {
 	// Do not want to treat as reference, traversal and cty.Value(literal) sogi use SetAttribute"Raw"
 	target.SetAttributeRaw(name, tokens)
 
 	return target
 }
 
+// attributeStringValue returns the value of the named attribute when it is a string literal.
+func attributeStringValue(body *hclwrite.Body, name string) (string, bool) {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return "", false
+	}
+
+	expr, diags := hclsyntax.ParseExpression(attr.Expr().BuildTokens(nil).Bytes(), name, hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", false
+	}
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return "", false
+	}
+
+	return value.AsString(), true
+}
+
+// reparseFile parses the file again from its tokens.
+// Expressions set from raw tokens, e.g. by merging blocks, are not parsed, so references in them
+// can be found only after the file is parsed again.
+func reparseFile(file *hclwrite.File) (*hclwrite.File, error) {
+	parsed, diags := hclwrite.ParseConfig(file.Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+	return parsed, nil
+}
+
+// newBlockFromBody returns a new block of the type and labels which has a copy of body.
+// The block is parsed from tokens so that it's independent of body and its structure is kept.
+func newBlockFromBody(blockType string, labels []string, body *hclwrite.Body) (*hclwrite.Block, error) {
+	block := hclwrite.NewBlock(blockType, labels)
+	block.Body().AppendUnstructuredTokens(body.BuildTokens(nil))
+
+	file, diags := hclwrite.ParseConfig(block.BuildTokens(nil).Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+	return file.Body().Blocks()[0], nil
+}
+
+// replaceBlock replaces oldBlock in body with newBlock, keeping the position of the block.
+func replaceBlock(body *hclwrite.Body, oldBlock *hclwrite.Block, newBlock *hclwrite.Block) {
+	blocks := body.Blocks()
+	index := slices.Index(blocks, oldBlock)
+	if index < 0 {
+		body.AppendBlock(newBlock)
+		return
+	}
+
+	for _, block := range blocks[index:] {
+		body.RemoveBlock(block)
+	}
+	body.AppendBlock(newBlock)
+	body.AppendNewline()
+	for _, block := range blocks[index+1:] {
+		body.AppendBlock(block)
+		body.AppendNewline()
+	}
+}
+
+// SetBackend merges the given backend block into the terraform block of the file.
+// The backend is merged with the existing one when the backend type is the same, and replaces it otherwise.
+// A terraform block is added when the file does not have one.
+func (p HCLParser) SetBackend(file *hclwrite.File, backend *hclwrite.Block) (*hclwrite.File, error) {
+	overlayBlock := hclwrite.NewBlock("terraform", nil)
+	overlayBlock.Body().AppendBlock(backend)
+
+	terraformBlock := file.Body().FirstMatchingBlock("terraform", nil)
+	if terraformBlock == nil {
+		file.Body().AppendBlock(overlayBlock)
+		file.Body().AppendNewline()
+		return file, nil
+	}
+
+	mergedBlock, err := mergeTerraformBlock(terraformBlock, overlayBlock)
+	if err != nil {
+		return nil, err
+	}
+	replaceBlock(file.Body(), terraformBlock, mergedBlock)
+
+	return file, nil
+}
+
+// ReplaceBackend replaces the backend or cloud block in the terraform blocks of the file with the given backend block.
+// Unlike SetBackend, the settings of the existing backend are not kept even when the backend type is the same.
+func (p HCLParser) ReplaceBackend(file *hclwrite.File, backend *hclwrite.Block) (*hclwrite.File, error) {
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "terraform" {
+			continue
+		}
+		for _, nestedBlock := range block.Body().Blocks() {
+			if nestedBlock.Type() == "backend" || nestedBlock.Type() == "cloud" {
+				block.Body().RemoveBlock(nestedBlock)
+			}
+		}
+	}
+
+	return p.SetBackend(file, backend)
+}
+
+// ExtractBackendConfig moves the settings of the backend block in the terraform blocks of the file into
+// the content of a file for 'terraform init -backend-config', and leaves only `backend "<type>" {}` in the file.
+// The file only accepts flat attributes, so it returns an error if the backend has nested blocks.
+func (p HCLParser) ExtractBackendConfig(file *hclwrite.File) (*hclwrite.File, []byte, error) {
+	var backend *hclwrite.Block
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "terraform" {
+			continue
+		}
+		for _, nestedBlock := range block.Body().Blocks() {
+			if nestedBlock.Type() == "backend" {
+				backend = nestedBlock
+			}
+		}
+	}
+	if backend == nil {
+		return nil, nil, fmt.Errorf("no backend block is found in the terraform block")
+	}
+	if nestedBlocks := backend.Body().Blocks(); len(nestedBlocks) != 0 {
+		return nil, nil, fmt.Errorf("%s has a nested block %q, which cannot be written to a -backend-config file", newBlockKey(backend), nestedBlocks[0].Type())
+	}
+
+	content := hclwrite.Format(bytes.TrimLeft(backend.Body().BuildTokens(nil).Bytes(), "\n"))
+	file, err := p.ReplaceBackend(file, hclwrite.NewBlock("backend", backend.Labels()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, content, nil
+}
+
+// attributeSource returns the source text of the named attribute expression, or an empty string.
+func attributeSource(body *hclwrite.Body, name string) string {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(attr.Expr().BuildTokens(nil).Bytes()))
+}
+
 func (p HCLParser) MergeFileBlocks(base *hclwrite.File, overlay *hclwrite.File) (*hclwrite.File, error) {
-	mergeBlocks(base.Body(), overlay.Body())
+	p.recordLabelAnnotations(base.Body())
+	p.recordLabelAnnotations(overlay.Body())
+
+	_, err := mergeBlocks(base.Body(), overlay.Body())
+	if err != nil {
+		return nil, err
+	}
 	return base, nil
 }
 
@@ -126,34 +380,40 @@ func mergeBlocks(base *hclwrite.Body, overlay *hclwrite.Body) (*hclwrite.Body, e
 	baseBlocks := base.Blocks()
 	overlayBlocks := overlay.Blocks()
 
-	tmpBlocks := map[string]map[string]*hclwrite.Block{}
+	tmpBlocks := map[string]*blockSet{}
 
-	baseLocals := map[string]*hclwrite.Attribute{}
+	baseLocals := map[string]hclwrite.Tokens{}
 	overlayLocals := map[string]*hclwrite.Attribute{}
 
 	for _, baseBlock := range baseBlocks {
-		// From the perspective of Terraform, it seems that only up to two labels can be used,
-		// but since the HCL specification allows setting three or more labels.
-		// We concatenate the labels into a string to use as a key.
-		joinedLabel := strings.Join(baseBlock.Labels(), "_")
+		key := newBlockKey(baseBlock)
 		blockType := baseBlock.Type()
 		if slices.Contains(tfUniqueBlockTypes, blockType) {
 			if tmpBlocks[blockType] == nil {
-				tmpBlocks[blockType] = map[string]*hclwrite.Block{}
+				tmpBlocks[blockType] = newBlockSet()
 			}
 
-			tmpBlocks[blockType][joinedLabel] = baseBlock
+			if existingBlock, ok := tmpBlocks[blockType].get(key); ok && blockType == "terraform" {
+				// A configuration can have multiple terraform blocks, e.g. versions.tf and backend.tf.
+				mergedBlock, err := mergeTerraformBlock(existingBlock, baseBlock)
+				if err != nil {
+					return nil, err
+				}
+				tmpBlocks[blockType].set(key, mergedBlock)
+			} else {
+				tmpBlocks[blockType].set(key, baseBlock)
+			}
 		} else if blockType == "locals" {
 			for name, attribute := range baseBlock.Body().Attributes() {
-				baseLocals[name] = attribute
+				baseLocals[name] = attribute.Expr().BuildTokens(nil)
 			}
 		} else if slices.Contains(tfNoLabelBlockTypes, blockType) {
 			if tmpBlocks[blockType] == nil {
-				tmpBlocks[blockType] = map[string]*hclwrite.Block{}
+				tmpBlocks[blockType] = newBlockSet()
 			}
 
-			joinedLabel = fmt.Sprintf("%s%d", joinedLabel, len(tmpBlocks[blockType]))
-			tmpBlocks[blockType][joinedLabel] = baseBlock
+			// Identical blocks are de-duplicated.
+			tmpBlocks[blockType].set(key, baseBlock)
 		} else {
 			_ = fmt.Errorf("warn: type %v has come. it's ignored.", blockType)
 		}
@@ -161,17 +421,25 @@ func mergeBlocks(base *hclwrite.Body, overlay *hclwrite.Body) (*hclwrite.Body, e
 	}
 
 	for _, overlayBlock := range overlayBlocks {
-		joinedLabel := strings.Join(overlayBlock.Labels(), "_")
+		key := newBlockKey(overlayBlock)
 		blockType := overlayBlock.Type()
-		slog.Debug("processing overlay blocks", "blockType", blockType, "joinedLabel", joinedLabel)
+		slog.Debug("processing overlay blocks", "key", key)
 
 		if slices.Contains(tfUniqueBlockTypes, blockType) {
-			if tmpBlock, ok := tmpBlocks[blockType][joinedLabel]; ok {
-				mergedBlock, err := mergeBlock(tmpBlock, overlayBlock)
+			if tmpBlock, ok := tmpBlocks[blockType].get(key); ok {
+				mergedBlock, err := mergeFuncFor(blockType)(tmpBlock, overlayBlock)
 				if err != nil {
 					return nil, err
 				}
-				tmpBlocks[blockType][joinedLabel] = mergedBlock
+				tmpBlocks[blockType].set(key, mergedBlock)
+			} else if err := checkNoBaseReference(overlayBlock); err != nil {
+				return nil, err
+			} else if blockType == "terraform" {
+				// Keep it to merge other terraform blocks of the overlay into it.
+				if tmpBlocks[blockType] == nil {
+					tmpBlocks[blockType] = newBlockSet()
+				}
+				tmpBlocks[blockType].set(key, overlayBlock)
 			} else {
 				base.AppendBlock(overlayBlock)
 				base.AppendNewline()
@@ -181,17 +449,27 @@ func mergeBlocks(base *hclwrite.Body, overlay *hclwrite.Body) (*hclwrite.Body, e
 				overlayLocals[name] = attribute
 			}
 		} else if slices.Contains(tfNoLabelBlockTypes, blockType) {
-			// There is no label to identify the block, so we just append it.
-			base.AppendBlock(overlayBlock)
-			base.AppendNewline()
+			if err := checkNoBaseReference(overlayBlock); err != nil {
+				return nil, err
+			}
+			if tmpBlocks[blockType] == nil {
+				tmpBlocks[blockType] = newBlockSet()
+			}
+
+			// Identical blocks are de-duplicated, and the overlay one replaces the base one.
+			tmpBlocks[blockType].set(key, overlayBlock)
 		} else {
 			_ = fmt.Errorf("warn: type %v has come", blockType)
 		}
 	}
 
-	if len(baseLocals) != 0 {
+	if len(baseLocals) != 0 || len(overlayLocals) != 0 {
 		for name, overlayLocalAttribute := range overlayLocals {
-			baseLocals[name] = overlayLocalAttribute
+			tokens, err := substituteBase(baseLocals[name], overlayLocalAttribute.Expr().BuildTokens(nil))
+			if err != nil {
+				return nil, fmt.Errorf("local.%s: %w", name, err)
+			}
+			baseLocals[name] = tokens
 		}
 
 		sortedNames := make([]string, 0, len(baseLocals))
@@ -214,29 +492,45 @@ func mergeBlocks(base *hclwrite.Body, overlay *hclwrite.Body) (*hclwrite.Body, e
 			slog.Debug("blockType is nil, so skipped", "blockType", blockType)
 			continue
 		}
-		for joinedLabel, block := range tmpBlocks[blockType] {
-			slog.Debug("processing result blocks", "joinedLabel", joinedLabel)
+		for _, block := range tmpBlocks[blockType].ordered() {
+			slog.Debug("processing result blocks", "key", newBlockKey(block))
 			base.AppendBlock(block)
 		}
 		base.AppendNewline()
 	}
 
+	if err := validateAddressBlocks(base); err != nil {
+		return nil, err
+	}
+
 	return base, nil
 }
 
+// mergeFuncFor returns the function to merge top-level blocks of the block type.
+func mergeFuncFor(blockType string) func(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwrite.Block, error) {
+	if blockType == "terraform" {
+		return mergeTerraformBlock
+	}
+	return mergeBlock
+}
+
 func mergeBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwrite.Block, error) {
 	resultBlock := hclwrite.NewBlock(baseBlock.Type(), baseBlock.Labels())
 	resultBlockBody := resultBlock.Body()
 	baseBlockBody := baseBlock.Body()
 	overlayBlockBody := overlayBlock.Body()
 
-	tmpAttributes := map[string]*hclwrite.Attribute{}
+	tmpAttributes := map[string]hclwrite.Tokens{}
 
 	for name, baseBlockBodyAttribute := range baseBlockBody.Attributes() {
-		tmpAttributes[name] = baseBlockBodyAttribute
+		tmpAttributes[name] = baseBlockBodyAttribute.Expr().BuildTokens(nil)
 	}
 	for name, overlayBlockBodyAttribute := range overlayBlockBody.Attributes() {
-		tmpAttributes[name] = overlayBlockBodyAttribute
+		tokens, err := substituteBase(tmpAttributes[name], overlayBlockBodyAttribute.Expr().BuildTokens(nil))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", newBlockKey(baseBlock), name, err)
+		}
+		tmpAttributes[name] = tokens
 	}
 
 	sortedNames := make([]string, 0, len(tmpAttributes))
@@ -251,6 +545,8 @@ func mergeBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwr
 	}
 
 	tmpBlocksForMerge := map[string]*hclwrite.Block{}
+	// mergeKeys keeps the order of the annotated blocks in the base, since a map is iterated randomly.
+	mergeKeys := []string{}
 	tmpBlocksForAppend := []*hclwrite.Block{}
 
 	for _, baseBlockBodyBlock := range baseBlockBody.Blocks() {
@@ -259,6 +555,9 @@ func mergeBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwr
 			mergeKey := string(annotationForBlockMerge)
 			slog.Debug("annotation is found in the base blocks", "annotation", mergeKey)
 
+			if _, ok := tmpBlocksForMerge[mergeKey]; !ok {
+				mergeKeys = append(mergeKeys, mergeKey)
+			}
 			tmpBlocksForMerge[mergeKey] = baseBlockBodyBlock
 		} else {
 			tmpBlocksForAppend = append(tmpBlocksForAppend, baseBlockBodyBlock)
@@ -282,6 +581,9 @@ func mergeBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwr
 				slog.Debug("annotation is found but it is not in the base blocks", "annotation", mergeKey)
 			}
 		} else {
+			if err := checkNoBaseReference(overlayBlockBodyBlock); err != nil {
+				return nil, fmt.Errorf("%s: %w", newBlockKey(baseBlock), err)
+			}
 			tmpBlocksForAppend = append(tmpBlocksForAppend, overlayBlockBodyBlock)
 		}
 	}
@@ -290,9 +592,9 @@ func mergeBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwr
 		resultBlockBody.AppendNewline()
 		resultBlockBody.AppendBlock(block)
 	}
-	for _, block := range tmpBlocksForMerge {
+	for _, mergeKey := range mergeKeys {
 		resultBlockBody.AppendNewline()
-		resultBlockBody.AppendBlock(block)
+		resultBlockBody.AppendBlock(tmpBlocksForMerge[mergeKey])
 	}
 
 	return resultBlock, nil
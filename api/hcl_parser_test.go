@@ -5,8 +5,10 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/stretchr/testify/assert"
 	"github.com/tk3fftk/tfustomize/api"
@@ -36,6 +38,41 @@ func TestCollectHCLFilePaths(t *testing.T) {
 			expect:  []string{"../test/collect_hcl_file_paths/1.tf"},
 			wantErr: false,
 		},
+		{
+			name:    "tofu files shadow tf files",
+			baseDir: "../test",
+			paths:   []string{"./collect_tofu_file_paths"},
+			expect:  []string{"../test/collect_tofu_file_paths/json.tofu.json", "../test/collect_tofu_file_paths/locals.tf.json", "../test/collect_tofu_file_paths/main.tofu", "../test/collect_tofu_file_paths/outputs.tofu", "../test/collect_tofu_file_paths/variables.tf"},
+			wantErr: false,
+		},
+		{
+			name:    "tofu file",
+			baseDir: "../test",
+			paths:   []string{"./collect_tofu_file_paths/outputs.tofu"},
+			expect:  []string{"../test/collect_tofu_file_paths/outputs.tofu"},
+			wantErr: false,
+		},
+		{
+			name:    "tofu file shadows tf file given explicitly",
+			baseDir: "../test",
+			paths:   []string{"./collect_tofu_file_paths/main.tf", "./collect_tofu_file_paths/main.tofu"},
+			expect:  []string{"../test/collect_tofu_file_paths/main.tofu"},
+			wantErr: false,
+		},
+		{
+			name:    "tofu.json file shadows tf.json file given explicitly",
+			baseDir: "../test",
+			paths:   []string{"./collect_tofu_file_paths/json.tf.json", "./collect_tofu_file_paths/json.tofu.json"},
+			expect:  []string{"../test/collect_tofu_file_paths/json.tofu.json"},
+			wantErr: false,
+		},
+		{
+			name:    "tf file without tofu file given explicitly",
+			baseDir: "../test",
+			paths:   []string{"./collect_tofu_file_paths/main.tf"},
+			expect:  []string{"../test/collect_tofu_file_paths/main.tf"},
+			wantErr: false,
+		},
 		{
 			name:    "not found",
 			baseDir: "../test",
@@ -206,6 +243,28 @@ func TestMergeFileBlocks(t *testing.T) {
     values = ["ubuntu/images/hvm-ssd/ubuntu-focal-24.04-amd64-server-*"]
   }
 }
+`,
+			wantErr: false,
+		},
+		{
+			name:    "data source with multiple merge blocks keeps the base order",
+			base:    []string{"base/data_with_multiple_block_merge.tf"},
+			overlay: []string{"overlay/data_with_multiple_block_merge.tf"},
+			expect: `data "aws_ami" "ubuntu" {
+  filter {
+    name   = "name"
+    values = ["ubuntu/images/hvm-ssd/ubuntu-noble-24.04-arm64-server-*"]
+  }
+  filter {
+    # tfustomize:merge_block:virtualization
+    name   = "virtualization-type"
+    values = ["hvm"]
+  }
+  filter {
+    name   = "architecture"
+    values = ["arm64"]
+  }
+}
 `,
 			wantErr: false,
 		},
@@ -232,24 +291,236 @@ resource "aws_instance" "web" {
 			wantErr: false,
 		},
 		{
-			name:    "all types of blocks",
-			base:    []string{"base/all_blocks.tf"},
-			overlay: []string{"overlay/all_blocks.tf"},
-			expect: `import {
-  to = aws_instance.example2
-  id = "i-qwer5678"
+			name:    "labels which are the same when joined are not merged",
+			base:    []string{"base/similar_labels.tf"},
+			overlay: []string{"overlay/similar_labels.tf"},
+			expect: `resource "aws_s3" "bucket_logs" {
+  name = "bucket-logs"
 }
-removed {
-  from = aws_instance.example2
-  lifecycle {
-    destroy = true
+resource "aws_s3_bucket" "logs" {
+  bucket = "production-logs"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "provider blocks are identified by alias",
+			base:    []string{"base/provider_alias.tf"},
+			overlay: []string{"overlay/provider_alias.tf"},
+			expect: `provider "aws" {
+  alias  = "eu_west_1"
+  region = "eu-west-1"
+}
+provider "aws" {
+  region = "ap-northeast-1"
+}
+provider "aws" {
+  alias  = "us_east_1"
+  region = "us-east-1"
+  assume_role {
+    role_arn = "arn:aws:iam::123456789012:role/production"
   }
 }
-moved {
-  from = aws_instance.old_name2
-  to   = aws_instance.new_name2
+`,
+			wantErr: false,
+		},
+		{
+			name:    "terraform settings are deep merged",
+			base:    []string{"base/terraform_settings.tf"},
+			overlay: []string{"overlay/terraform_settings.tf"},
+			expect: `terraform {
+  experiments      = [module_variable_optional_attrs, config_driven_move]
+  required_version = ">= 1.5"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 6.0"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+  backend "s3" {
+    bucket = "tfstate-production"
+    key    = "app/terraform.tfstate"
+    region = "ap-northeast-1"
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "terraform backend is replaced when the type is changed",
+			base:    []string{"base/terraform_settings.tf"},
+			overlay: []string{"overlay/terraform_settings_backend_type.tf"},
+			expect: `terraform {
+  experiments      = [module_variable_optional_attrs]
+  required_version = ">= 1.5"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+  backend "gcs" {
+    bucket = "tfstate-production"
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "terraform backend is replaced by cloud",
+			base:    []string{"base/terraform_settings.tf"},
+			overlay: []string{"overlay/terraform_settings_cloud.tf"},
+			expect: `terraform {
+  experiments      = [module_variable_optional_attrs]
+  required_version = ">= 1.5"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+  cloud {
+    organization = "example"
+    workspaces {
+      name = "app-production"
+    }
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "terraform settings objects other than required_providers are replaced",
+			base:    []string{"base/terraform_settings_object.tf"},
+			overlay: []string{"overlay/terraform_settings_object.tf"},
+			expect: `terraform {
+  backend "s3" {
+    assume_role = {
+      role_arn = "arn:aws:iam::123456789012:role/production"
+    }
+    bucket = "tfstate-staging"
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "terraform blocks only in overlay are merged",
+			base:    []string{"base/data_without_block.tf"},
+			overlay: []string{"overlay/terraform_settings_split.tf"},
+			expect: `data "aws_ami" "ubuntu" {
+  executable_users = ["self"]
+  name_regex       = "^myami-\\d{3}"
+  owners           = ["self"]
+}
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 6.0"
+    }
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "conflicting moved blocks",
+			base:    []string{"address_blocks/base/moved.tf"},
+			overlay: []string{"address_blocks/overlay/moved.tf"},
+			expect:  "",
+			wantErr: true,
+		},
+		{
+			name:    "removed addresses still declared or imported are only warned",
+			base:    []string{"address_blocks/base/removed.tf"},
+			overlay: []string{"address_blocks/overlay/removed.tf"},
+			expect: `resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+}
+import {
+  to = module.app.aws_instance.web[0]
+  id = "i-abcd1234"
+}
+removed {
+  from = aws_instance.web
+}
+removed {
+  from = module.app
 }
-locals {
+`,
+			wantErr: false,
+		},
+		{
+			name:    "locals only in overlay",
+			base:    []string{"base/data_without_block.tf"},
+			overlay: []string{"overlay/only_locals.tf"},
+			expect: `locals {
+  a = 1
+  b = 2
+  d = 4
+}
+data "aws_ami" "ubuntu" {
+  executable_users = ["self"]
+  name_regex       = "^myami-\\d{3}"
+  owners           = ["self"]
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "overlay expressions referring to the base value",
+			base:    []string{"base/base_reference.tf"},
+			overlay: []string{"overlay/base_reference.tf"},
+			expect: `locals {
+  azs = concat(["ap-northeast-1a", "ap-northeast-1c"], ["ap-northeast-1d"])
+}
+resource "aws_instance" "web" {
+  count           = (var.instance_count + 1) * 2
+  security_groups = concat(["sg-base"], ["sg-prod"])
+  user_data       = "${"echo base"} && echo production"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "overlay expressions referring to the base value which does not exist",
+			base:    []string{"base/base_reference.tf"},
+			overlay: []string{"overlay/base_reference_without_base.tf"},
+			wantErr: true,
+		},
+		{
+			name:    "overlay block only in overlay referring to the base value",
+			base:    []string{"base/base_reference.tf"},
+			overlay: []string{"overlay/base_reference_new_block.tf"},
+			wantErr: true,
+		},
+		{
+			name:    "appended nested block referring to the base value",
+			base:    []string{"base/base_reference.tf"},
+			overlay: []string{"overlay/base_reference_nested_block.tf"},
+			wantErr: true,
+		},
+		{
+			name:    "terraform block only in overlay referring to the base value",
+			base:    []string{"base/base_reference.tf"},
+			overlay: []string{"overlay/base_reference_terraform_block.tf"},
+			wantErr: true,
+		},
+		{
+			name:    "all types of blocks",
+			base:    []string{"base/all_blocks.tf"},
+			overlay: []string{"overlay/all_blocks.tf"},
+			expect: `locals {
   a = 1
   b = 2
 }
@@ -290,16 +561,30 @@ moved {
   from = aws_instance.old_name
   to   = aws_instance.new_name
 }
+moved {
+  from = aws_instance.old_name2
+  to   = aws_instance.new_name2
+}
 import {
   to = aws_instance.example
   id = "i-abcd1234"
 }
+import {
+  to = aws_instance.example2
+  id = "i-qwer5678"
+}
 removed {
   from = aws_instance.example
   lifecycle {
     destroy = false
   }
 }
+removed {
+  from = aws_instance.example2
+  lifecycle {
+    destroy = true
+  }
+}
 `,
 			wantErr: false,
 		},
@@ -328,12 +613,302 @@ removed {
 			}
 
 			result, err := parser.MergeFileBlocks(baseHCL, overlayHCL)
-			if err != nil && !tt.wantErr {
+			if (err != nil) != tt.wantErr {
 				t.Errorf("MergeFileBlocks() error = %v, wantErr %v", err, tt.wantErr)
-				assert.Fail(t, "unexpected error")
-			} else {
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		backend string
+		expect  string
+		wantErr bool
+	}{
+		{
+			name: "merge into the backend of the same type",
+			content: `terraform {
+  required_version = ">= 1.5"
+  backend "s3" {
+    bucket = "tfstate-staging"
+    key    = "app/terraform.tfstate"
+  }
+}
+`,
+			backend: `backend "s3" {
+  bucket = "tfstate-production"
+}
+`,
+			expect: `terraform {
+  required_version = ">= 1.5"
+  backend "s3" {
+    bucket = "tfstate-production"
+    key    = "app/terraform.tfstate"
+  }
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "replace the backend of another type",
+			content: `resource "aws_instance" "web" {
+}
+terraform {
+  backend "local" {
+    path = "terraform.tfstate"
+  }
+}
+output "id" {
+  value = aws_instance.web.id
+}
+`,
+			backend: `backend "s3" {
+  bucket = "tfstate-production"
+}
+`,
+			expect: `resource "aws_instance" "web" {
+}
+terraform {
+  backend "s3" {
+    bucket = "tfstate-production"
+  }
+}
+output "id" {
+  value = aws_instance.web.id
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "add a terraform block",
+			content: ``,
+			backend: `backend "s3" {
+  bucket = "tfstate-production"
+}
+`,
+			expect: `terraform {
+  backend "s3" {
+    bucket = "tfstate-production"
+  }
+}
+`,
+			wantErr: false,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			backendFile, diags := hclwrite.ParseConfig([]byte(tt.backend), "backend.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.SetBackend(file, backendFile.Body().Blocks()[0])
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetBackend() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
 				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
 			}
 		})
 	}
 }
+
+func TestReplaceBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		backend string
+		expect  string
+	}{
+		{
+			name: "backend of the same type",
+			content: `terraform {
+  required_version = ">= 1.5"
+  backend "s3" {
+    bucket = "tfstate-staging"
+    key    = "app/terraform.tfstate"
+  }
+}
+`,
+			backend: `backend "s3" {
+}
+`,
+			expect: `terraform {
+  required_version = ">= 1.5"
+  backend "s3" {
+  }
+}
+`,
+		},
+		{
+			name: "cloud block",
+			content: `terraform {
+  cloud {
+    organization = "example"
+  }
+}
+`,
+			backend: `backend "s3" {
+}
+`,
+			expect: `terraform {
+  backend "s3" {
+  }
+}
+`,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			backendFile, diags := hclwrite.ParseConfig([]byte(tt.backend), "backend.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.ReplaceBackend(file, backendFile.Body().Blocks()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+		})
+	}
+}
+
+func TestExtractBackendConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		expect       string
+		expectConfig string
+		wantErr      bool
+	}{
+		{
+			name: "merged backend of the same type",
+			content: `terraform {
+  required_version = ">= 1.5"
+  backend "s3" {
+    bucket = "tfstate-prod"
+    key    = "app/terraform.tfstate"
+  }
+}
+`,
+			expect: `terraform {
+  required_version = ">= 1.5"
+  backend "s3" {
+  }
+}
+`,
+			expectConfig: `bucket = "tfstate-prod"
+key    = "app/terraform.tfstate"
+`,
+			wantErr: false,
+		},
+		{
+			name: "nested block",
+			content: `terraform {
+  backend "s3" {
+    bucket = "tfstate-prod"
+    assume_role {
+      role_arn = "arn:aws:iam::123456789012:role/terraform"
+    }
+  }
+}
+`,
+			wantErr: true,
+		},
+		{
+			name: "no backend",
+			content: `terraform {
+  required_version = ">= 1.5"
+}
+`,
+			wantErr: true,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, config, err := parser.ExtractBackendConfig(file)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ExtractBackendConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+				assert.Equal(t, tt.expectConfig, string(config))
+			}
+		})
+	}
+}
+
+func TestSetBackendWithBackendConfig(t *testing.T) {
+	content := `terraform {
+  backend "s3" {
+    bucket = "tfstate-staging"
+    key    = "app/terraform.tfstate"
+  }
+}
+`
+	conf, err := api.LoadConfig("../test/variables/tfustomization.hcl", map[string]string{"env": "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := conf.Backend.BuildBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := api.HCLParser{}
+	file, diags := hclwrite.ParseConfig([]byte(content), "main.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	file, err = parser.SetBackend(file, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, config, err := parser.ExtractBackendConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The base settings don't stay in the configuration, and the settings of the config win.
+	assert.Equal(t, `terraform {
+  backend "s3" {
+  }
+}
+`, strings.TrimLeft(regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"), "\n"))
+	assert.Equal(t, `bucket = "tfstate-prod"
+key    = "app/prod/terraform.tfstate"
+region = "ap-northeast-1"
+`, string(config))
+}
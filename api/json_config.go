@@ -0,0 +1,324 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"golang.org/x/exp/slices"
+)
+
+// jsonConfigLabelCounts are the numbers of labels of top-level blocks in JSON configuration files.
+var jsonConfigLabelCounts = map[string]int{
+	"check":     1,
+	"data":      2,
+	"import":    0,
+	"locals":    0,
+	"module":    1,
+	"moved":     0,
+	"output":    1,
+	"provider":  1,
+	"removed":   0,
+	"resource":  2,
+	"terraform": 0,
+	"variable":  1,
+}
+
+// jsonConfigNestedBlocks are the nested blocks defined by the language itself, and the numbers of their labels,
+// keyed by the type of the parent block. Any other object property is an attribute.
+var jsonConfigNestedBlocks = map[string]map[string]int{
+	"check":       {"assert": 0, "data": 2},
+	"cloud":       {"workspaces": 0},
+	"data":        {"dynamic": 1, "lifecycle": 0},
+	"dynamic":     {"content": 0},
+	"lifecycle":   {"postcondition": 0, "precondition": 0},
+	"output":      {"precondition": 0},
+	"provisioner": {"connection": 0},
+	"removed":     {"connection": 0, "lifecycle": 0, "provisioner": 1},
+	"resource":    {"connection": 0, "dynamic": 1, "lifecycle": 0, "provisioner": 1},
+	"terraform":   {"backend": 1, "cloud": 0, "provider_meta": 1, "required_providers": 0},
+	"variable":    {"validation": 0},
+}
+
+// jsonConfigProviderBodies are the blocks whose nested blocks are defined by the provider schemas.
+var jsonConfigProviderBodies = []string{"content", "data", "provider", "resource"}
+
+// jsonConfigReferenceAttributes are the attributes whose strings are expressions such as references
+// instead of templates, keyed by the type of the parent block.
+var jsonConfigReferenceAttributes = map[string][]string{
+	"data":      {"depends_on", "provider"},
+	"dynamic":   {"iterator"},
+	"import":    {"provider", "to"},
+	"lifecycle": {"ignore_changes", "replace_triggered_by"},
+	"module":    {"depends_on", "providers"},
+	"moved":     {"from", "to"},
+	"output":    {"depends_on"},
+	"removed":   {"from"},
+	"resource":  {"depends_on", "provider"},
+	"variable":  {"type"},
+}
+
+// jsonConfigComment is the property name of a comment in a block body.
+const jsonConfigComment = "//"
+
+// jsonProperty is a property of a JSON object, which keeps the order of the properties.
+type jsonProperty struct {
+	name  string
+	value interface{}
+}
+
+type jsonObject []jsonProperty
+
+// decodeJSONValue decodes a JSON value into a jsonObject, a []interface{}, a string, a json.Number, a bool or nil.
+func decodeJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		var object jsonObject
+		for decoder.More() {
+			name, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonProperty{name: name.(string), value: value})
+		}
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// jsonConfigToHCL converts a configuration in JSON syntax, such as a .tf.json file, into native syntax.
+// A JSON body can't be told apart into attributes and blocks without the provider schemas, so an object
+// property is a nested block only when the language defines the block, e.g. lifecycle, or when it's an array
+// of objects in a resource, data or provider block. Strings are templates except in references such as depends_on.
+func jsonConfigToHCL(src []byte, filename string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	decoder.UseNumber()
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%s: unexpected content after the top-level object", filename)
+	}
+	root, ok := value.(jsonObject)
+	if !ok {
+		return nil, fmt.Errorf("%s: the top-level value must be an object", filename)
+	}
+
+	w := &jsonConfigWriter{}
+	for _, property := range root {
+		if property.name == jsonConfigComment {
+			continue
+		}
+		labelCount, ok := jsonConfigLabelCounts[property.name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unsupported block type %q", filename, property.name)
+		}
+		if err := w.writeBlocks(property.name, nil, labelCount, property.value, false); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+
+	return hclwrite.Format(w.buf.Bytes()), nil
+}
+
+type jsonConfigWriter struct {
+	buf bytes.Buffer
+}
+
+// writeBlocks writes the blocks of the type whose labels are nested as object properties,
+// e.g. {"aws_instance": {"web": {...}}} for resource blocks. A body can be an array of bodies for multiple blocks.
+func (w *jsonConfigWriter) writeBlocks(blockType string, labels []string, labelCount int, value interface{}, providerBody bool) error {
+	if len(labels) < labelCount {
+		object, ok := value.(jsonObject)
+		if !ok {
+			return fmt.Errorf("%s block must have %d labels as object properties", blockType, labelCount)
+		}
+		for _, property := range object {
+			if err := w.writeBlocks(blockType, append(append([]string{}, labels...), property.name), labelCount, property.value, providerBody); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	bodies, ok := value.([]interface{})
+	if !ok {
+		bodies = []interface{}{value}
+	}
+	for _, body := range bodies {
+		object, ok := body.(jsonObject)
+		if !ok {
+			return fmt.Errorf("body of %s block must be an object", blockType)
+		}
+		w.buf.WriteString(blockType)
+		for _, label := range labels {
+			fmt.Fprintf(&w.buf, " %q", label)
+		}
+		w.buf.WriteString(" {\n")
+		if err := w.writeBody(blockType, object, providerBody || slices.Contains(jsonConfigProviderBodies, blockType)); err != nil {
+			return err
+		}
+		w.buf.WriteString("}\n")
+	}
+	return nil
+}
+
+func (w *jsonConfigWriter) writeBody(blockType string, object jsonObject, providerBody bool) error {
+	for _, property := range object {
+		if property.name == jsonConfigComment {
+			continue
+		}
+		if labelCount, ok := jsonConfigNestedBlocks[blockType][property.name]; ok {
+			if err := w.writeBlocks(property.name, nil, labelCount, property.value, false); err != nil {
+				return err
+			}
+			continue
+		}
+		if providerBody && isJSONObjectArray(property.value) {
+			if err := w.writeBlocks(property.name, nil, 0, property.value, true); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !hclsyntax.ValidIdentifier(property.name) {
+			return fmt.Errorf("attribute name %q of %s block is invalid", property.name, blockType)
+		}
+		w.buf.WriteString(property.name + " = ")
+		w.writeExpression(property.value, slices.Contains(jsonConfigReferenceAttributes[blockType], property.name))
+		w.buf.WriteString("\n")
+	}
+	return nil
+}
+
+func isJSONObjectArray(value interface{}) bool {
+	array, ok := value.([]interface{})
+	if !ok || len(array) == 0 {
+		return false
+	}
+	for _, elem := range array {
+		if _, ok := elem.(jsonObject); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// writeExpression writes a JSON value as an expression. Strings are written as they are when reference is true.
+func (w *jsonConfigWriter) writeExpression(value interface{}, reference bool) {
+	switch v := value.(type) {
+	case nil:
+		w.buf.WriteString("null")
+	case bool:
+		fmt.Fprintf(&w.buf, "%t", v)
+	case json.Number:
+		w.buf.WriteString(v.String())
+	case string:
+		if reference {
+			w.buf.WriteString(v)
+		} else {
+			w.buf.WriteString(quoteJSONTemplate(v))
+		}
+	case []interface{}:
+		w.buf.WriteString("[")
+		for i, elem := range v {
+			if i != 0 {
+				w.buf.WriteString(", ")
+			}
+			w.writeExpression(elem, reference)
+		}
+		w.buf.WriteString("]")
+	case jsonObject:
+		w.buf.WriteString("{\n")
+		for _, property := range v {
+			if reference || hclsyntax.ValidIdentifier(property.name) {
+				w.buf.WriteString(property.name)
+			} else {
+				w.buf.WriteString(quoteJSONTemplate(property.name))
+			}
+			w.buf.WriteString(" = ")
+			w.writeExpression(property.value, reference)
+			w.buf.WriteString("\n")
+		}
+		w.buf.WriteString("}")
+	}
+}
+
+var jsonTemplateLiteralEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// quoteJSONTemplate returns a quoted template of a string in JSON syntax, which is a template too.
+// Literal parts are escaped, and interpolations and directives such as `${var.name}` are copied as they are.
+func quoteJSONTemplate(s string) string {
+	var result strings.Builder
+	result.WriteString(`"`)
+
+	literalStart := 0
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], "$${") || strings.HasPrefix(s[i:], "%%{") {
+			i += 2
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") && !strings.HasPrefix(s[i:], "%{") {
+			continue
+		}
+		end := templateSequenceEnd(s, i+2)
+		result.WriteString(jsonTemplateLiteralEscaper.Replace(s[literalStart:i]))
+		result.WriteString(s[i:end])
+		literalStart = end
+		i = end - 1
+	}
+	result.WriteString(jsonTemplateLiteralEscaper.Replace(s[literalStart:]))
+
+	result.WriteString(`"`)
+	return result.String()
+}
+
+// templateSequenceEnd returns the index after the closing brace of an interpolation or a directive
+// which starts before start, skipping braces in quoted strings in it.
+func templateSequenceEnd(s string, start int) int {
+	depth := 1
+	quoted := false
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
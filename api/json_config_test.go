@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestReadHCLFileJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		expect   string
+		wantErr  bool
+	}{
+		{
+			name:     "blocks, attributes and references",
+			filename: "../test/json_config/main.tf.json",
+			expect: `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+  backend "s3" {
+    bucket = "tfstate"
+  }
+}
+provider "aws" {
+  region = "ap-northeast-1"
+}
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+variable "subnets" {
+  type = list(object({ cidr = string }))
+  default = [{
+    cidr = "10.0.1.0/24"
+  }]
+}
+locals {
+  name    = "web-${var.env}"
+  escaped = "say \"hi\"\n$${literal}"
+  policy  = "${jsonencode({ "Version" = "2012-10-17" })}"
+}
+resource "aws_instance" "web" {
+  ami        = "${data.aws_ami.ubuntu.id}"
+  count      = 2
+  provider   = aws.west
+  depends_on = [aws_s3_bucket.logs]
+  tags = {
+    Name                 = "web"
+    "kubernetes.io/role" = "node"
+  }
+  ebs_block_device {
+    device_name = "/dev/sdb"
+    volume_size = 8
+  }
+  lifecycle {
+    ignore_changes = [tags]
+  }
+  provisioner "local-exec" {
+    command = "echo ${self.id}"
+  }
+}
+module "vpc" {
+  source = "../modules/vpc"
+  providers = {
+    aws = aws.west
+  }
+  subnets = [{
+    cidr = "10.0.1.0/24"
+  }]
+}
+moved {
+  from = aws_instance.old
+  to   = aws_instance.web
+}
+output "id" {
+  value       = "${aws_instance.web[0].id}"
+  sensitive   = false
+  description = null
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:     "unsupported block type",
+			filename: "../test/json_config/unsupported_block_type.tf.json",
+			wantErr:  true,
+		},
+		{
+			name:     "top-level value is not an object",
+			filename: "../test/json_config/not_object.tf.json",
+			wantErr:  true,
+		},
+	}
+
+	parser := api.NewHCLParser()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := parser.ReadHCLFile(tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadHCLFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(file.Bytes())))
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// moduleLocalizer copies local modules into the modules directory of the output directory.
+type moduleLocalizer struct {
+	parser    HCLParser
+	outputDir string
+	// names are the names of the copied modules keyed by their absolute source directories.
+	names map[string]string
+}
+
+// isInside reports whether path is dir or is in dir.
+func isInside(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// localize rewrites local module sources in body to the copied modules, and copies them.
+// dir is the directory which the sources are relative to, fileDir is the directory where body is written,
+// and root is the source directory of the module which body belongs to, or "" for the root module.
+// A module in root is kept as it is, since it's copied together.
+func (l *moduleLocalizer) localize(body *hclwrite.Body, dir string, fileDir string, root string) error {
+	for _, block := range body.Blocks() {
+		if block.Type() != "module" {
+			continue
+		}
+		source, ok := attributeStringValue(block.Body(), "source")
+		if !ok || !isLocalPath(source) {
+			continue
+		}
+		absSource, err := filepath.Abs(filepath.Join(dir, source))
+		if err != nil {
+			return err
+		}
+		if root != "" && isInside(root, absSource) {
+			continue
+		}
+
+		name, err := l.copyModule(absSource)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(fileDir, filepath.Join(l.outputDir, "modules", name))
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !isLocalPath(rel) {
+			rel = "./" + rel
+		}
+		block.Body().SetAttributeValue("source", cty.StringVal(rel))
+	}
+	return nil
+}
+
+// copyModule copies the module at the absolute source directory once, and returns its name.
+func (l *moduleLocalizer) copyModule(source string) (string, error) {
+	if name, ok := l.names[source]; ok {
+		return name, nil
+	}
+
+	name := filepath.Base(source)
+	used := map[string]bool{}
+	for _, existing := range l.names {
+		used[existing] = true
+	}
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s_%d", filepath.Base(source), i)
+	}
+	l.names[source] = name
+
+	destination := filepath.Join(l.outputDir, "modules", name)
+	return name, filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".terraform" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destination, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if isHCLFile(entry.Name()) {
+			file, err := l.parser.ReadHCLFile(path)
+			if err != nil {
+				return err
+			}
+			if err := l.localize(file.Body(), filepath.Dir(path), filepath.Dir(target), source); err != nil {
+				return err
+			}
+			content = file.Bytes()
+		}
+		return os.WriteFile(target, content, fileInfo.Mode().Perm())
+	})
+}
+
+// LocalizeModules copies every local module referred to from the file, recursively, into modules/<name>
+// in OutputDir and rewrites the sources, so that OutputDir is self-contained.
+// Sources in the file must be relative to OutputDir.
+func (p HCLParser) LocalizeModules(file *hclwrite.File) (*hclwrite.File, error) {
+	if p.OutputDir == "" {
+		return nil, fmt.Errorf("the output directory is required to localize modules")
+	}
+
+	localizer := &moduleLocalizer{parser: p, outputDir: p.OutputDir, names: map[string]string{}}
+	if err := localizer.localize(file.Body(), p.OutputDir, p.OutputDir, ""); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,61 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestLocalizeModules(t *testing.T) {
+	outputDir := t.TempDir()
+	parser := api.HCLParser{OutputDir: outputDir}
+
+	file, err := parser.ConcatFiles([]string{"../test/localize_modules/overlay/main.tf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := parser.LocalizeModules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `module "app" {
+  source = "./modules/app"
+}
+module "common" {
+  source = "./modules/common"
+}
+module "other_common" {
+  source = "./modules/common_2"
+}
+module "vpc" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+`, string(hclwrite.Format(result.Bytes())))
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "modules/app/main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `module "submodule" {
+  source = "./submodule"
+}
+
+module "common" {
+  source = "../common"
+}
+`, string(content))
+
+	for _, path := range []string{
+		"modules/app/submodule/main.tf",
+		"modules/common/main.tf",
+		"modules/common/script.sh",
+		"modules/common_2/main.tf",
+	} {
+		assert.FileExists(t, filepath.Join(outputDir, path))
+	}
+}
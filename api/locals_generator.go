@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"gopkg.in/yaml.v3"
+)
+
+// readDataFile reads a JSON, YAML or CSV file as a value. The format is decided by the extension.
+// A CSV file is a list of objects keyed by the header row.
+func readDataFile(path string) (cty.Value, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		ty, err := ctyjson.ImpliedType(src)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("%s: %w", path, err)
+		}
+		return ctyjson.Unmarshal(src, ty)
+	case ".yaml", ".yml":
+		var data interface{}
+		if err := yaml.Unmarshal(src, &data); err != nil {
+			return cty.NilVal, fmt.Errorf("%s: %w", path, err)
+		}
+		return goValueToCty(data)
+	case ".csv":
+		records, err := csv.NewReader(bytes.NewReader(src)).ReadAll()
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(records) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		rows := make([]cty.Value, 0, len(records)-1)
+		for _, record := range records[1:] {
+			row := map[string]cty.Value{}
+			for i, column := range records[0] {
+				row[column] = cty.StringVal(record[i])
+			}
+			rows = append(rows, cty.ObjectVal(row))
+		}
+		return cty.TupleVal(rows), nil
+	}
+
+	return cty.NilVal, fmt.Errorf("%s: the data file must be .json, .yaml, .yml or .csv", path)
+}
+
+// goValueToCty converts a value decoded from YAML into a value.
+func goValueToCty(data interface{}) (cty.Value, error) {
+	switch v := data.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case string:
+		return cty.StringVal(v), nil
+	case bool:
+		return cty.BoolVal(v), nil
+	case int:
+		return cty.NumberIntVal(int64(v)), nil
+	case int64:
+		return cty.NumberIntVal(v), nil
+	case uint64:
+		return cty.NumberUIntVal(v), nil
+	case float64:
+		// A number can't be NaN or infinity, e.g. `.nan` and `.inf` in YAML.
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return cty.NilVal, fmt.Errorf("unsupported number %v", v)
+		}
+		return cty.NumberVal(big.NewFloat(v)), nil
+	case []interface{}:
+		elems := make([]cty.Value, 0, len(v))
+		for _, elem := range v {
+			value, err := goValueToCty(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems = append(elems, value)
+		}
+		return cty.TupleVal(elems), nil
+	case map[string]interface{}:
+		attributes := map[string]cty.Value{}
+		for key, elem := range v {
+			value, err := goValueToCty(elem)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attributes[key] = value
+		}
+		return cty.ObjectVal(attributes), nil
+	}
+	return cty.NilVal, fmt.Errorf("unsupported value %v of type %T", data, data)
+}
+
+// GenerateLocals returns a locals block which has the contents of the data files of the generators.
+// A generator with a name emits one local value of the name, and one without a name emits
+// every item of the top-level object as a local value. Paths are relative to baseDir.
+func GenerateLocals(baseDir string, generators []LocalsGenerator) (*hclwrite.Block, error) {
+	values := map[string]cty.Value{}
+	for _, generator := range generators {
+		value, err := readDataFile(filepath.Join(baseDir, generator.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		if generator.Name != "" {
+			values[generator.Name] = value
+			continue
+		}
+		if !value.Type().IsObjectType() {
+			return nil, fmt.Errorf("%s must be an object to generate locals without a name", generator.Path)
+		}
+		for name, attribute := range value.AsValueMap() {
+			values[name] = attribute
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !hclsyntax.ValidIdentifier(name) {
+			return nil, fmt.Errorf("%q is not a valid name of a local value", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	block := hclwrite.NewBlock("locals", nil)
+	for _, name := range names {
+		block.Body().SetAttributeValue(name, values[name])
+	}
+	return block, nil
+}
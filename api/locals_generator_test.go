@@ -0,0 +1,92 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestGenerateLocals(t *testing.T) {
+	tests := []struct {
+		name       string
+		generators []api.LocalsGenerator
+		expect     string
+		wantErr    bool
+	}{
+		{
+			name: "json, yaml and csv",
+			generators: []api.LocalsGenerator{
+				{Path: "network.yaml"},
+				{Path: "accounts.json", Name: "accounts"},
+				{Path: "allowed_ips.csv", Name: "allowed_ips"},
+			},
+			expect: `locals {
+  accounts = {
+    production = "123456789012"
+    staging    = "210987654321"
+  }
+  allowed_ips = [{
+    cidr = "203.0.113.0/24"
+    name = "office"
+    }, {
+    cidr = "198.51.100.10/32"
+    name = "vpn"
+  }]
+  az_count           = 2
+  enable_nat_gateway = true
+  subnet_cidrs       = ["10.0.1.0/24", "10.0.2.0/24"]
+  vpc_cidr           = "10.0.0.0/16"
+}
+`,
+		},
+		{
+			name: "large numbers",
+			generators: []api.LocalsGenerator{
+				{Path: "large_numbers.yaml"},
+			},
+			expect: `locals {
+  max_uint64 = 18446744073709551615
+  min_int64  = -9223372036854775808
+}
+`,
+		},
+		{
+			name: "nan",
+			generators: []api.LocalsGenerator{
+				{Path: "nan.yaml"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "csv without a name",
+			generators: []api.LocalsGenerator{
+				{Path: "allowed_ips.csv"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported format",
+			generators: []api.LocalsGenerator{
+				{Path: "tfustomization.hcl"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := api.GenerateLocals("../test/locals_generator", tt.generators)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateLocals() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			file := hclwrite.NewEmptyFile()
+			file.Body().AppendBlock(block)
+			assert.Equal(t, tt.expect, string(hclwrite.Format(file.Bytes())))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/exp/slices"
+)
+
+const lockFileName = ".terraform.lock.hcl"
+
+const lockFileHeader = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+`
+
+// CollectLockFilePaths returns the dependency lock files in the directories of the given paths.
+// For a file, the lock file in its directory is returned.
+func (p HCLParser) CollectLockFilePaths(baseDir string, paths []string) ([]string, error) {
+	var collectedPaths []string
+
+	for _, path := range paths {
+		dir := filepath.Join(baseDir, path)
+		fileInfo, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !fileInfo.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+
+		lockFilePath := filepath.Join(dir, lockFileName)
+		if _, err := os.Stat(lockFilePath); err != nil || slices.Contains(collectedPaths, lockFilePath) {
+			continue
+		}
+		collectedPaths = append(collectedPaths, lockFilePath)
+	}
+
+	return collectedPaths, nil
+}
+
+// lockedHashes returns the hashes of a provider block in a lock file.
+func lockedHashes(block *hclwrite.Block) ([]string, error) {
+	attr := block.Body().GetAttribute("hashes")
+	if attr == nil {
+		return nil, nil
+	}
+	expr, diags := hclsyntax.ParseExpression(attr.Expr().BuildTokens(nil).Bytes(), "hashes", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+	if !value.CanIterateElements() {
+		return nil, fmt.Errorf("hashes of provider %q must be a list", block.Labels())
+	}
+
+	var hashes []string
+	for _, hash := range value.AsValueSlice() {
+		if hash.Type() != cty.String {
+			return nil, fmt.Errorf("hashes of provider %q must be strings", block.Labels())
+		}
+		hashes = append(hashes, hash.AsString())
+	}
+	return hashes, nil
+}
+
+// hashesTokens returns a list of the hashes, one per line as terraform init writes.
+func hashesTokens(hashes []string) hclwrite.Tokens {
+	tokens := hclwrite.Tokens{
+		{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")},
+		{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+	}
+	for _, hash := range hashes {
+		tokens = append(tokens, hclwrite.TokensForValue(cty.StringVal(hash))...)
+		tokens = append(tokens,
+			&hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")},
+			&hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		)
+	}
+	return append(tokens, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+}
+
+// MergeLockFiles merges dependency lock files by provider. A provider in a later file replaces the one
+// in earlier files, and their hashes are unioned when they lock the same version.
+func (p HCLParser) MergeLockFiles(paths []string) (*hclwrite.File, error) {
+	providers := map[string]*hclwrite.Block{}
+
+	for _, path := range paths {
+		file, err := p.ReadHCLFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range file.Body().Blocks() {
+			if block.Type() != "provider" || len(block.Labels()) != 1 {
+				continue
+			}
+			address := block.Labels()[0]
+
+			existing, ok := providers[address]
+			if !ok {
+				providers[address] = block
+				continue
+			}
+			existingVersion, _ := attributeStringValue(existing.Body(), "version")
+			version, _ := attributeStringValue(block.Body(), "version")
+			if existingVersion == version {
+				existingHashes, err := lockedHashes(existing)
+				if err != nil {
+					return nil, err
+				}
+				hashes, err := lockedHashes(block)
+				if err != nil {
+					return nil, err
+				}
+				for _, hash := range existingHashes {
+					if !slices.Contains(hashes, hash) {
+						hashes = append(hashes, hash)
+					}
+				}
+				sort.Strings(hashes)
+				block.Body().SetAttributeRaw("hashes", hashesTokens(hashes))
+			}
+			providers[address] = block
+		}
+	}
+
+	addresses := make([]string, 0, len(providers))
+	for address := range providers {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	file := hclwrite.NewEmptyFile()
+	file.Body().AppendUnstructuredTokens(hclwrite.Tokens{
+		{Type: hclsyntax.TokenComment, Bytes: []byte(lockFileHeader)},
+	})
+	for i, address := range addresses {
+		if i > 0 {
+			file.Body().AppendNewline()
+		}
+		file.Body().AppendBlock(providers[address])
+	}
+	return file, nil
+}
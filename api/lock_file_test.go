@@ -0,0 +1,53 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestCollectLockFilePaths(t *testing.T) {
+	got, err := api.NewHCLParser().CollectLockFilePaths("../test/lock_files", []string{"./base", "./overlay/main.tf", "./overlay", "../collect_hcl_file_paths"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"../test/lock_files/base/.terraform.lock.hcl", "../test/lock_files/overlay/.terraform.lock.hcl"}, got)
+}
+
+func TestMergeLockFiles(t *testing.T) {
+	result, err := api.NewHCLParser().MergeLockFiles([]string{"../test/lock_files/base/.terraform.lock.hcl", "../test/lock_files/overlay/.terraform.lock.hcl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.31.0"
+  constraints = "~> 5.31"
+  hashes = [
+    "h1:base-linux",
+    "h1:overlay-darwin",
+    "zh:0000000000000000000000000000000000000000000000000000000000000000",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/random" {
+  version     = "3.6.0"
+  constraints = "~> 3.6"
+  hashes = [
+    "h1:random-overlay",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/tls" {
+  version = "4.0.4"
+  hashes = [
+    "h1:tls-base",
+  ]
+}
+`, string(hclwrite.Format(result.Bytes())))
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+var templateLiteralEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", "$${", "%{", "%%{")
+
+// templateLiteralToken returns a token of a literal part of a quoted template.
+func templateLiteralToken(s string) *hclwrite.Token {
+	return &hclwrite.Token{Type: hclsyntax.TokenQuotedLit, Bytes: []byte(templateLiteralEscaper.Replace(s))}
+}
+
+// affixTokens adds prefix and suffix inside the quotes of a string expression.
+// It reports false when the expression is not a quoted string without any reference, e.g. `var.name`
+// or `"${aws_s3_bucket.logs.id}"`, since the name comes from somewhere else.
+func affixTokens(tokens hclwrite.Tokens, prefix string, suffix string) (hclwrite.Tokens, bool) {
+	if len(tokens) < 2 || tokens[0].Type != hclsyntax.TokenOQuote || tokens[len(tokens)-1].Type != hclsyntax.TokenCQuote {
+		return nil, false
+	}
+	expr, diags := hclsyntax.ParseExpression(tokens.Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() || len(expr.Variables()) != 0 {
+		return nil, false
+	}
+
+	var result hclwrite.Tokens
+	result = append(result, tokens[0])
+	if prefix != "" {
+		result = append(result, templateLiteralToken(prefix))
+	}
+	result = append(result, tokens[1:len(tokens)-1]...)
+	if suffix != "" {
+		result = append(result, templateLiteralToken(suffix))
+	}
+	result = append(result, tokens[len(tokens)-1])
+
+	return result, true
+}
+
+// AddNameAffix adds prefix and suffix to the names of every resource, data and module block,
+// e.g. `aws_s3_bucket.logs` becomes `aws_s3_bucket.prod_logs`, and rewrites every reference to them.
+// The given attributes of resource blocks, such as `name` or `bucket`, also get the prefix and suffix
+// when they are string literals. Attributes referring to anything are left as they are.
+func (p HCLParser) AddNameAffix(file *hclwrite.File, prefix string, suffix string, attributes []string) (*hclwrite.File, error) {
+	if prefix == "" && suffix == "" {
+		return file, nil
+	}
+
+	file, err := reparseFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []blockAddress
+	for _, block := range file.Body().Blocks() {
+		if _, ok := declaredAddress(block); !ok {
+			continue
+		}
+		addresses = append(addresses, blockAddress{blockType: block.Type(), labels: block.Labels()})
+	}
+
+	// Rename via temporary names so that a new name never collides with a name which is not renamed yet,
+	// e.g. `logs` to `prod_logs` while `prod_logs` is also declared.
+	temporaries := make([]blockAddress, 0, len(addresses))
+	for i, address := range addresses {
+		temporary := renamedAddress(address, fmt.Sprintf("tfustomize_affix_%d", i))
+		if err := renameBlock(file.Body(), address, temporary, false); err != nil {
+			return nil, err
+		}
+		temporaries = append(temporaries, temporary)
+	}
+	for i, address := range addresses {
+		name := address.labels[len(address.labels)-1]
+		if err := renameBlock(file.Body(), temporaries[i], renamedAddress(address, prefix+name+suffix), false); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "resource" {
+			continue
+		}
+		for _, name := range attributes {
+			attr := block.Body().GetAttribute(name)
+			if attr == nil {
+				continue
+			}
+			tokens, ok := affixTokens(attr.Expr().BuildTokens(nil), prefix, suffix)
+			if !ok {
+				slog.Warn("The attribute is not a string literal, so it doesn't get the prefix and suffix", "address", strings.Join(block.Labels(), "."), "attribute", name)
+				continue
+			}
+			block.Body().SetAttributeRaw(name, tokens)
+		}
+	}
+
+	return reparseFile(file)
+}
+
+// renamedAddress returns the address with the last label, i.e. the name, replaced.
+func renamedAddress(address blockAddress, name string) blockAddress {
+	labels := append([]string{}, address.labels...)
+	labels[len(labels)-1] = name
+	return blockAddress{blockType: address.blockType, labels: labels}
+}
@@ -0,0 +1,166 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestAddNameAffix(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		prefix     string
+		suffix     string
+		attributes []string
+		expect     string
+		wantErr    bool
+	}{
+		{
+			name: "prefix and suffix",
+			content: `resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+resource "aws_s3_bucket" "prod_logs" {
+  bucket = var.bucket_name
+}
+data "aws_iam_policy_document" "logs" {
+  statement {
+    resources = ["${aws_s3_bucket.logs.arn}/*"]
+  }
+}
+module "vpc" {
+  source = "terraform-aws-modules/vpc/aws"
+  name   = "vpc"
+}
+output "ids" {
+  value = [aws_s3_bucket.logs.id, aws_s3_bucket.prod_logs.id, module.vpc.vpc_id, data.aws_iam_policy_document.logs.json]
+}
+`,
+			prefix:     "prod_",
+			suffix:     "_v2",
+			attributes: []string{"bucket", "name"},
+			expect: `resource "aws_s3_bucket" "prod_logs_v2" {
+  bucket = "prod_logs_v2"
+}
+resource "aws_s3_bucket" "prod_prod_logs_v2" {
+  bucket = var.bucket_name
+}
+data "aws_iam_policy_document" "prod_logs_v2" {
+  statement {
+    resources = ["${aws_s3_bucket.prod_logs_v2.arn}/*"]
+  }
+}
+module "prod_vpc_v2" {
+  source = "terraform-aws-modules/vpc/aws"
+  name   = "vpc"
+}
+output "ids" {
+  value = [aws_s3_bucket.prod_logs_v2.id, aws_s3_bucket.prod_prod_logs_v2.id, module.prod_vpc_v2.vpc_id, data.aws_iam_policy_document.prod_logs_v2.json]
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "attributes with references are not affixed",
+			content: `resource "aws_s3_bucket" "logs" {
+  bucket = "logs-${var.env}"
+}
+resource "aws_s3_bucket_policy" "logs" {
+  bucket = aws_s3_bucket.logs.id
+}
+resource "aws_s3_bucket_logging" "logs" {
+  bucket = "${aws_s3_bucket.logs.id}"
+  name   = "logging-${"v1"}"
+}
+`,
+			prefix:     "prod_",
+			attributes: []string{"bucket", "name"},
+			expect: `resource "aws_s3_bucket" "prod_logs" {
+  bucket = "logs-${var.env}"
+}
+resource "aws_s3_bucket_policy" "prod_logs" {
+  bucket = aws_s3_bucket.prod_logs.id
+}
+resource "aws_s3_bucket_logging" "prod_logs" {
+  bucket = "${aws_s3_bucket.prod_logs.id}"
+  name   = "prod_logging-${"v1"}"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "no affix",
+			content: `resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+`,
+			attributes: []string{"bucket"},
+			expect: `resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+`,
+			wantErr: false,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.AddNameAffix(file, tt.prefix, tt.suffix, tt.attributes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AddNameAffix() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
+
+func TestAddNameAffixAfterMerge(t *testing.T) {
+	base, diags := hclwrite.ParseConfig([]byte(`resource "aws_s3_bucket" "logs" {
+}
+resource "aws_s3_bucket_policy" "logs" {
+  bucket = aws_s3_bucket.logs.id
+}
+`), "base.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	overlay, diags := hclwrite.ParseConfig([]byte(`resource "aws_s3_bucket_policy" "logs" {
+  policy = "{}"
+}
+`), "overlay.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	parser := api.HCLParser{}
+	merged, err := parser.MergeFileBlocks(base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := parser.AddNameAffix(merged, "prod_", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `resource "aws_s3_bucket" "prod_logs" {
+}
+resource "aws_s3_bucket_policy" "prod_logs" {
+  bucket = aws_s3_bucket.prod_logs.id
+  policy = "{}"
+}
+`, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+var regexpPathSegment = regexp.MustCompile(`^([\w-]+)(?:\[(\d+)\])?$`)
+
+// pathLabelCounts are the numbers of labels of top-level blocks which can be addressed by a path.
+var pathLabelCounts = map[string]int{
+	"data":      2,
+	"locals":    0,
+	"module":    1,
+	"output":    1,
+	"provider":  1,
+	"resource":  2,
+	"terraform": 0,
+	"variable":  1,
+}
+
+// pathSegment is an attribute name, or a nested block type with an optional index among the blocks of the type.
+type pathSegment struct {
+	name  string
+	index int
+}
+
+// operationPath is a path such as `resource.aws_instance.web.ebs_block_device[1].volume_size`,
+// which consists of a top-level block type, its labels and segments in the block.
+type operationPath struct {
+	raw       string
+	blockType string
+	labels    []string
+	segments  []pathSegment
+}
+
+func parseOperationPath(path string) (operationPath, error) {
+	parts := strings.Split(path, ".")
+	labelCount, ok := pathLabelCounts[parts[0]]
+	if !ok {
+		return operationPath{}, fmt.Errorf("path %q must start with one of the block types which can be addressed", path)
+	}
+	if len(parts) < labelCount+2 {
+		return operationPath{}, fmt.Errorf("path %q must have %d labels and an attribute or a nested block", path, labelCount)
+	}
+
+	result := operationPath{
+		raw:       path,
+		blockType: parts[0],
+		labels:    parts[1 : labelCount+1],
+	}
+	for _, part := range parts[labelCount+1:] {
+		match := regexpPathSegment.FindStringSubmatch(part)
+		if match == nil {
+			return operationPath{}, fmt.Errorf("path %q has an invalid segment %q", path, part)
+		}
+		segment := pathSegment{name: match[1], index: -1}
+		if match[2] != "" {
+			segment.index, _ = strconv.Atoi(match[2])
+		}
+		result.segments = append(result.segments, segment)
+	}
+
+	return result, nil
+}
+
+// pathTarget is the last segment of a path and the body which contains it.
+type pathTarget struct {
+	body    *hclwrite.Body
+	segment pathSegment
+}
+
+func (t pathTarget) attribute() *hclwrite.Attribute {
+	if t.segment.index >= 0 {
+		return nil
+	}
+	return t.body.GetAttribute(t.segment.name)
+}
+
+func (t pathTarget) block() (*hclwrite.Block, error) {
+	return selectNestedBlock(t.body, t.segment)
+}
+
+// selectNestedBlock returns the nested block of the segment. The index can be omitted only when
+// there is exactly one block of the type.
+func selectNestedBlock(body *hclwrite.Body, segment pathSegment) (*hclwrite.Block, error) {
+	var blocks []*hclwrite.Block
+	for _, block := range body.Blocks() {
+		if block.Type() == segment.name {
+			blocks = append(blocks, block)
+		}
+	}
+
+	switch {
+	case len(blocks) == 0:
+		return nil, fmt.Errorf("%s is not found", segment.name)
+	case segment.index >= len(blocks):
+		return nil, fmt.Errorf("%s[%d] is out of range: there are %d blocks", segment.name, segment.index, len(blocks))
+	case segment.index >= 0:
+		return blocks[segment.index], nil
+	case len(blocks) > 1:
+		return nil, fmt.Errorf("%s is ambiguous: there are %d blocks, so specify an index", segment.name, len(blocks))
+	}
+	return blocks[0], nil
+}
+
+// providerAlias returns the alias addressed by a provider path and the segments after it.
+// The first segment is the alias when a provider block of the name has it as its alias,
+// e.g. `provider.aws.west.region`. Otherwise the path addresses the default provider.
+func (path operationPath) providerAlias(body *hclwrite.Body) (string, []pathSegment) {
+	if path.blockType != "provider" || len(path.segments) < 2 || path.segments[0].index >= 0 {
+		return "", path.segments
+	}
+	for _, block := range body.Blocks() {
+		if block.Type() != "provider" || !slices.Equal(block.Labels(), path.labels) {
+			continue
+		}
+		if alias := newBlockKey(block).alias; alias == path.segments[0].name {
+			return alias, path.segments[1:]
+		}
+	}
+	return "", path.segments
+}
+
+// resolve returns the target of the path in body.
+// When forAdd is true, a locals block is added if the path is a local value which is not declared.
+func (path operationPath) resolve(body *hclwrite.Body, forAdd bool) (pathTarget, error) {
+	var block *hclwrite.Block
+	segments := path.segments
+	if path.blockType == "locals" {
+		if len(segments) != 1 {
+			return pathTarget{}, fmt.Errorf("path %q must be locals.<name>", path.raw)
+		}
+		for _, localsBlock := range body.Blocks() {
+			if localsBlock.Type() == "locals" && (block == nil || localsBlock.Body().GetAttribute(segments[0].name) != nil) {
+				block = localsBlock
+			}
+		}
+		if block == nil && forAdd {
+			block = body.AppendNewBlock("locals", nil)
+		}
+	} else {
+		var alias string
+		alias, segments = path.providerAlias(body)
+		for _, candidate := range body.Blocks() {
+			key := newBlockKey(candidate)
+			if candidate.Type() == path.blockType && slices.Equal(candidate.Labels(), path.labels) && key.alias == alias {
+				block = candidate
+				break
+			}
+		}
+	}
+	if block == nil {
+		return pathTarget{}, fmt.Errorf("path %q is not found", path.raw)
+	}
+
+	for _, segment := range segments[:len(segments)-1] {
+		nestedBlock, err := selectNestedBlock(block.Body(), segment)
+		if err != nil {
+			return pathTarget{}, fmt.Errorf("path %q: %w", path.raw, err)
+		}
+		block = nestedBlock
+	}
+
+	return pathTarget{body: block.Body(), segment: segments[len(segments)-1]}, nil
+}
+
+func normalizedSource(tokens hclwrite.Tokens) string {
+	return strings.TrimSpace(string(hclwrite.Format(tokens.Bytes())))
+}
+
+// applyOperation applies a JSON-Patch like operation to body.
+func applyOperation(body *hclwrite.Body, op Operation) error {
+	path, err := parseOperationPath(op.Path)
+	if err != nil {
+		return err
+	}
+	if (op.Op == "add" || op.Op == "replace" || op.Op == "test") && op.Value == nil {
+		return fmt.Errorf("op %q requires value", op.Op)
+	}
+
+	switch op.Op {
+	case "add":
+		target, err := path.resolve(body, true)
+		if err != nil {
+			return err
+		}
+		if target.segment.index >= 0 {
+			return fmt.Errorf("path %q must be an attribute to add", op.Path)
+		}
+		target.body.SetAttributeRaw(target.segment.name, op.Value)
+	case "replace", "test":
+		target, err := path.resolve(body, false)
+		if err != nil {
+			return err
+		}
+		attr := target.attribute()
+		if attr == nil {
+			return fmt.Errorf("attribute %q is not found", op.Path)
+		}
+		if op.Op == "replace" {
+			target.body.SetAttributeRaw(target.segment.name, op.Value)
+			break
+		}
+		if actual, expected := normalizedSource(attr.Expr().BuildTokens(nil)), normalizedSource(op.Value); actual != expected {
+			return fmt.Errorf("test failed: %s is %s, not %s", op.Path, actual, expected)
+		}
+	case "remove":
+		target, err := path.resolve(body, false)
+		if err != nil {
+			return err
+		}
+		if target.attribute() != nil {
+			target.body.RemoveAttribute(target.segment.name)
+			break
+		}
+		block, err := target.block()
+		if err != nil {
+			return fmt.Errorf("path %q: %w", op.Path, err)
+		}
+		target.body.RemoveBlock(block)
+	case "move", "copy":
+		if op.From == "" {
+			return fmt.Errorf("op %q requires from", op.Op)
+		}
+		fromPath, err := parseOperationPath(op.From)
+		if err != nil {
+			return err
+		}
+		source, err := fromPath.resolve(body, false)
+		if err != nil {
+			return err
+		}
+		target, err := path.resolve(body, true)
+		if err != nil {
+			return err
+		}
+
+		if attr := source.attribute(); attr != nil {
+			tokens := attr.Expr().BuildTokens(nil)
+			if op.Op == "move" {
+				source.body.RemoveAttribute(source.segment.name)
+			}
+			target.body.SetAttributeRaw(target.segment.name, tokens)
+			break
+		}
+		block, err := source.block()
+		if err != nil {
+			return fmt.Errorf("path %q: %w", op.From, err)
+		}
+		copiedBlock, err := newBlockFromBody(target.segment.name, block.Labels(), block.Body())
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			source.body.RemoveBlock(block)
+		}
+		target.body.AppendBlock(copiedBlock)
+	default:
+		return fmt.Errorf("unsupported op %q: it must be one of add, remove, replace, move, copy and test", op.Op)
+	}
+
+	return nil
+}
+
+// ApplyOperations applies path-addressed operations in order, such as
+// replacing `resource.aws_instance.web.ebs_block_device[1].volume_size`.
+// It stops at the first operation which fails, including a failed test operation.
+func (p HCLParser) ApplyOperations(file *hclwrite.File, ops []Operation) (*hclwrite.File, error) {
+	for i, op := range ops {
+		if err := applyOperation(file.Body(), op); err != nil {
+			return nil, fmt.Errorf("operation #%d (%s %s): %w", i+1, op.Op, op.Path, err)
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,214 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func valueTokens(t *testing.T, src string) hclwrite.Tokens {
+	t.Helper()
+	file, diags := hclwrite.ParseConfig([]byte("value = "+src), "value.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	return file.Body().GetAttribute("value").Expr().BuildTokens(nil)
+}
+
+func TestApplyOperations(t *testing.T) {
+	content := `locals {
+  env = "staging"
+}
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+  tags = {
+    Name = "web"
+  }
+  ebs_block_device {
+    device_name = "/dev/sdb"
+    volume_size = 10
+  }
+  ebs_block_device {
+    device_name = "/dev/sdc"
+    volume_size = 20
+  }
+}
+terraform {
+  required_version = ">= 1.5"
+}
+`
+
+	tests := []struct {
+		name    string
+		ops     []api.Operation
+		expect  string
+		wantErr bool
+	}{
+		{
+			name: "add, replace and remove",
+			ops: []api.Operation{
+				{Op: "test", Path: "resource.aws_instance.web.ebs_block_device[1].volume_size", Value: valueTokens(t, "20")},
+				{Op: "replace", Path: "resource.aws_instance.web.ebs_block_device[1].volume_size", Value: valueTokens(t, "100")},
+				{Op: "add", Path: "resource.aws_instance.web.monitoring", Value: valueTokens(t, "true")},
+				{Op: "add", Path: "locals.region", Value: valueTokens(t, `"ap-northeast-1"`)},
+				{Op: "remove", Path: "resource.aws_instance.web.ebs_block_device[0]"},
+				{Op: "remove", Path: "terraform.required_version"},
+			},
+			expect: `locals {
+  env    = "staging"
+  region = "ap-northeast-1"
+}
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+  tags = {
+    Name = "web"
+  }
+  ebs_block_device {
+    device_name = "/dev/sdc"
+    volume_size = 100
+  }
+  monitoring = true
+}
+terraform {
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "move and copy",
+			ops: []api.Operation{
+				{Op: "move", From: "resource.aws_instance.web.tags", Path: "resource.aws_instance.web.volume_tags"},
+				{Op: "copy", From: "resource.aws_instance.web.ebs_block_device[0]", Path: "resource.aws_instance.web.ebs_block_device"},
+				{Op: "copy", From: "locals.env", Path: "terraform.env"},
+			},
+			expect: `locals {
+  env = "staging"
+}
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+  ebs_block_device {
+    device_name = "/dev/sdb"
+    volume_size = 10
+  }
+  ebs_block_device {
+    device_name = "/dev/sdc"
+    volume_size = 20
+  }
+  volume_tags = {
+    Name = "web"
+  }
+  ebs_block_device {
+    device_name = "/dev/sdb"
+    volume_size = 10
+  }
+}
+terraform {
+  required_version = ">= 1.5"
+  env              = "staging"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "failed test",
+			ops: []api.Operation{
+				{Op: "test", Path: "resource.aws_instance.web.instance_type", Value: valueTokens(t, `"t3.large"`)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ambiguous nested block",
+			ops: []api.Operation{
+				{Op: "replace", Path: "resource.aws_instance.web.ebs_block_device.volume_size", Value: valueTokens(t, "100")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "index out of range",
+			ops: []api.Operation{
+				{Op: "remove", Path: "resource.aws_instance.web.ebs_block_device[2]"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "replace an attribute which is not found",
+			ops: []api.Operation{
+				{Op: "replace", Path: "resource.aws_instance.web.ami", Value: valueTokens(t, `"ami-0c94855ba95c574c8"`)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "block is not found",
+			ops: []api.Operation{
+				{Op: "add", Path: "resource.aws_instance.api.monitoring", Value: valueTokens(t, "true")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported op",
+			ops: []api.Operation{
+				{Op: "merge", Path: "resource.aws_instance.web.monitoring", Value: valueTokens(t, "true")},
+			},
+			wantErr: true,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.ApplyOperations(file, tt.ops)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApplyOperations() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
+
+func TestApplyOperationsProviderAlias(t *testing.T) {
+	content := `provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+provider "aws" {
+  region = "ap-northeast-1"
+}
+`
+
+	parser := api.HCLParser{}
+
+	file, diags := hclwrite.ParseConfig([]byte(content), "main.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	result, err := parser.ApplyOperations(file, []api.Operation{
+		{Op: "replace", Path: "provider.aws.region", Value: valueTokens(t, `"eu-west-1"`)},
+		{Op: "replace", Path: "provider.aws.west.region", Value: valueTokens(t, `"us-west-1"`)},
+		{Op: "add", Path: "provider.aws.west.profile", Value: valueTokens(t, `"west"`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `provider "aws" {
+  alias   = "west"
+  region  = "us-west-1"
+  profile = "west"
+}
+provider "aws" {
+  region = "eu-west-1"
+}
+`, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+var regexpPlaceholder = regexp.MustCompile(`\{\{\s*\.([\w-]+)\s*\}\}`)
+
+var heredocLiteralEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+// replacePlaceholders replaces placeholders in a literal part of a string template.
+// Placeholders without a value are left untouched, since they may be for another tool, e.g. in a heredoc template.
+func replacePlaceholders(token *hclwrite.Token, values map[string]string) {
+	escaper := templateLiteralEscaper
+	if token.Type == hclsyntax.TokenStringLit {
+		escaper = heredocLiteralEscaper
+	}
+
+	replaced := regexpPlaceholder.ReplaceAllStringFunc(string(token.Bytes), func(placeholder string) string {
+		name := regexpPlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := values[name]
+		if !ok {
+			slog.Debug("The placeholder has no value, so it's left untouched", "placeholder", placeholder)
+			return placeholder
+		}
+		return escaper.Replace(value)
+	})
+	token.Bytes = []byte(replaced)
+}
+
+// ReplacePlaceholders replaces placeholders such as `{{ .env }}` in string literals with the given values,
+// e.g. `"{{ .env }}-logs"` becomes `"production-logs"`. Placeholders without a value are left untouched.
+func (p HCLParser) ReplacePlaceholders(file *hclwrite.File, values map[string]string) *hclwrite.File {
+	walkAttributes(file.Body(), nil, func(attr *hclwrite.Attribute) {
+		for _, token := range attr.Expr().BuildTokens(nil) {
+			if token.Type != hclsyntax.TokenQuotedLit && token.Type != hclsyntax.TokenStringLit {
+				continue
+			}
+			replacePlaceholders(token, values)
+		}
+	})
+
+	return file
+}
@@ -0,0 +1,96 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestReplacePlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		values  map[string]string
+		expect  string
+	}{
+		{
+			name: "string literals",
+			content: `resource "aws_s3_bucket" "logs" {
+  bucket = "{{ .env }}-logs"
+  tags = {
+    Name = "${var.name}-{{.env}}"
+  }
+  policy = <<EOT
+{"Resource": "arn:aws:s3:::{{ .env }}-logs/*"}
+EOT
+}
+`,
+			values: map[string]string{"env": "production"},
+			expect: `resource "aws_s3_bucket" "logs" {
+  bucket = "production-logs"
+  tags = {
+    Name = "${var.name}-production"
+  }
+  policy = <<EOT
+{"Resource": "arn:aws:s3:::production-logs/*"}
+EOT
+}
+`,
+		},
+		{
+			name: "values are escaped",
+			content: `locals {
+  name = "{{ .name }}"
+}
+`,
+			values: map[string]string{"name": `"${x}"`},
+			expect: `locals {
+  name = "\"$${x}\""
+}
+`,
+		},
+		{
+			name: "undefined placeholder is left untouched",
+			content: `locals {
+  name = "{{ .undefined }}-{{ .env }}"
+}
+`,
+			values: map[string]string{"env": "production"},
+			expect: `locals {
+  name = "{{ .undefined }}-production"
+}
+`,
+		},
+		{
+			name: "heredoc without placeholders configured",
+			content: `resource "aws_ssm_document" "template" {
+  content = <<EOT
+Hello, {{ .Foo }}
+EOT
+}
+`,
+			values: nil,
+			expect: `resource "aws_ssm_document" "template" {
+  content = <<EOT
+Hello, {{ .Foo }}
+EOT
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result := api.NewHCLParser().ReplacePlaceholders(file, tt.values)
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
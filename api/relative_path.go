@@ -0,0 +1,99 @@
+package api
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// isLocalPath reports whether a module source is a local path, which Terraform requires to start with ./ or ../.
+func isLocalPath(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// relocatePath returns the path relative to outputDir of the path relative to sourceDir.
+func relocatePath(sourceDir string, outputDir string, path string) (string, error) {
+	absSource, err := filepath.Abs(filepath.Join(sourceDir, path))
+	if err != nil {
+		return "", err
+	}
+	absOutput, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absOutput, absSource)
+	if err != nil {
+		return "", err
+	}
+
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(path, "/") && !strings.HasSuffix(rel, "/") {
+		rel += "/"
+	}
+	return rel, nil
+}
+
+// isPathModule reports whether the tokens at i are the interpolation `${path.module}`.
+func isPathModule(tokens hclwrite.Tokens, i int) bool {
+	if i+4 >= len(tokens) {
+		return false
+	}
+	return tokens[i].Type == hclsyntax.TokenTemplateInterp &&
+		tokens[i+1].Type == hclsyntax.TokenIdent && string(tokens[i+1].Bytes) == "path" &&
+		tokens[i+2].Type == hclsyntax.TokenDot &&
+		tokens[i+3].Type == hclsyntax.TokenIdent && string(tokens[i+3].Bytes) == "module" &&
+		tokens[i+4].Type == hclsyntax.TokenTemplateSeqEnd
+}
+
+// rewriteRelativePaths rewrites local module sources and paths such as "${path.module}/policy.json"
+// in a file read from sourceDir, so that they are relative to outputDir where the result is written.
+// Paths to assets are kept, since the assets are copied into outputDir.
+func (p HCLParser) rewriteRelativePaths(file *hclwrite.File, sourceDir string) error {
+	var err error
+
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "module" {
+			continue
+		}
+		source, ok := attributeStringValue(block.Body(), "source")
+		if !ok || !isLocalPath(source) {
+			continue
+		}
+		rel, relErr := relocatePath(sourceDir, p.OutputDir, source)
+		if relErr != nil {
+			return relErr
+		}
+		if !isLocalPath(rel) {
+			rel = "./" + rel
+		}
+		block.Body().SetAttributeValue("source", cty.StringVal(rel))
+	}
+
+	walkAttributes(file.Body(), nil, func(attr *hclwrite.Attribute) {
+		tokens := attr.Expr().BuildTokens(nil)
+		for i := range tokens {
+			if !isPathModule(tokens, i) || i+5 >= len(tokens) || tokens[i+5].Type != hclsyntax.TokenQuotedLit {
+				continue
+			}
+			literal := tokens[i+5]
+			path := string(literal.Bytes)
+			if !strings.HasPrefix(path, "/") {
+				continue
+			}
+			if _, ok := p.Assets[filepath.FromSlash(strings.TrimPrefix(path, "/"))]; ok {
+				continue
+			}
+			rel, relErr := relocatePath(sourceDir, p.OutputDir, path[1:])
+			if relErr != nil {
+				err = relErr
+				return
+			}
+			literal.Bytes = []byte("/" + rel)
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,183 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestConcatFilesRewriteRelativePaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		outputDir string
+		assets    map[string]string
+		expect    string
+	}{
+		{
+			name:      "output in the overlay directory",
+			outputDir: "../test/relative_paths/overlay/generated",
+			expect: `module "app" {
+  source = "../../modules/app"
+}
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/../../base/policy.json")
+}
+resource "aws_instance" "app" {
+  user_data = templatefile("${path.module}/../../base/templates/user_data.sh", {})
+  tags = {
+    Template = "${path.module}/../../base/templates/${var.name}.tpl"
+  }
+}
+`,
+		},
+		{
+			name:      "assets are kept",
+			outputDir: "../test/relative_paths/overlay/generated",
+			assets: map[string]string{
+				"templates/user_data.sh": "../test/relative_paths/base/templates/user_data.sh",
+			},
+			expect: `module "app" {
+  source = "../../modules/app"
+}
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/../../base/policy.json")
+}
+resource "aws_instance" "app" {
+  user_data = templatefile("${path.module}/templates/user_data.sh", {})
+  tags = {
+    Template = "${path.module}/../../base/templates/${var.name}.tpl"
+  }
+}
+`,
+		},
+		{
+			name:      "output in a sibling directory",
+			outputDir: "../test/relative_paths/generated",
+			expect: `module "app" {
+  source = "../modules/app"
+}
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/../base/policy.json")
+}
+resource "aws_instance" "app" {
+  user_data = templatefile("${path.module}/../base/templates/user_data.sh", {})
+  tags = {
+    Template = "${path.module}/../base/templates/${var.name}.tpl"
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := api.HCLParser{OutputDir: tt.outputDir, Assets: tt.assets}
+			result, err := parser.ConcatFiles([]string{"../test/relative_paths/base/main.tf"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
+
+func TestConcatFilesRewriteRelativePathsWithAssets(t *testing.T) {
+	tests := []struct {
+		name   string
+		assets []string
+		expect string
+	}{
+		{
+			name:   "asset directory of the referred path",
+			assets: []string{"./base/templates", "./base/policy.json"},
+			expect: `module "app" {
+  source = "../../modules/app"
+}
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/policy.json")
+}
+resource "aws_instance" "app" {
+  user_data = templatefile("${path.module}/templates/user_data.sh", {})
+  tags = {
+    Template = "${path.module}/../../base/templates/${var.name}.tpl"
+  }
+}
+`,
+		},
+		{
+			// Assets are keyed by the basename of the directory, so the files are copied as base/...,
+			// and the paths which don't start with base/ still refer to the original files.
+			name:   "asset directory of a parent directory",
+			assets: []string{"./base"},
+			expect: `module "app" {
+  source = "../../modules/app"
+}
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/../../base/policy.json")
+}
+resource "aws_instance" "app" {
+  user_data = templatefile("${path.module}/../../base/templates/user_data.sh", {})
+  tags = {
+    Template = "${path.module}/../../base/templates/${var.name}.tpl"
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := api.NewHCLParser()
+			parser.OutputDir = "../test/relative_paths/overlay/generated"
+			assets, err := parser.CollectAssets("../test/relative_paths", tt.assets)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser.Assets = assets
+
+			result, err := parser.ConcatFiles([]string{"../test/relative_paths/base/main.tf"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
+
+func TestConcatBlocksRewriteRelativePaths(t *testing.T) {
+	conf, err := api.LoadConfig("../test/relative_paths/overlay/tfustomization.hcl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := api.NewHCLParser()
+	parser.OutputDir = "../test/relative_paths/overlay/generated"
+	result, err := parser.ConcatBlocks(conf.Patches.Inline, "../test/relative_paths/overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `module "extra" {
+  source = "../../modules/extra"
+}
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/../policy.json")
+}
+`, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// blockAddress is the address of a resource, data or module block split into its traversal steps.
+type blockAddress struct {
+	blockType string
+	labels    []string
+}
+
+// parseBlockAddress parses an address such as `aws_instance.web`, `data.aws_ami.ubuntu` or `module.vpc`.
+func parseBlockAddress(address string) (blockAddress, error) {
+	parts := strings.Split(address, ".")
+	switch {
+	case len(parts) == 2 && parts[0] == "module":
+		return blockAddress{blockType: "module", labels: parts[1:]}, nil
+	case len(parts) == 3 && parts[0] == "data":
+		return blockAddress{blockType: "data", labels: parts[1:]}, nil
+	case len(parts) == 2 && parts[0] != "data":
+		return blockAddress{blockType: "resource", labels: parts}, nil
+	}
+	return blockAddress{}, fmt.Errorf("%q is not an address of a resource, data or module", address)
+}
+
+// traversal returns the steps of the address used in references.
+func (a blockAddress) traversal() []string {
+	if a.blockType == "resource" {
+		return a.labels
+	}
+	return append([]string{a.blockType}, a.labels...)
+}
+
+func (a blockAddress) String() string {
+	return strings.Join(a.traversal(), ".")
+}
+
+// walkAttributes calls fn for every attribute in body and its nested blocks.
+// Blocks are skipped when skip returns true for them.
+func walkAttributes(body *hclwrite.Body, skip func(block *hclwrite.Block) bool, fn func(attr *hclwrite.Attribute)) {
+	for _, attr := range body.Attributes() {
+		fn(attr)
+	}
+	for _, block := range body.Blocks() {
+		if skip != nil && skip(block) {
+			continue
+		}
+		walkAttributes(block.Body(), skip, fn)
+	}
+}
+
+// renameBlock relabels the block at the from address and rewrites every reference to it.
+// When keepMoved is true, references in moved blocks are kept because they describe the history of addresses.
+func renameBlock(body *hclwrite.Body, from blockAddress, to blockAddress, keepMoved bool) error {
+	if from.blockType != to.blockType || (from.blockType != "module" && from.labels[0] != to.labels[0]) {
+		return fmt.Errorf("%s cannot be renamed to %s: block type and resource type must be the same", from, to)
+	}
+	if body.FirstMatchingBlock(to.blockType, to.labels) != nil {
+		return fmt.Errorf("%s cannot be renamed to %s: %s is already declared", from, to, to)
+	}
+	block := body.FirstMatchingBlock(from.blockType, from.labels)
+	if block == nil {
+		return fmt.Errorf("%s cannot be renamed: it is not declared", from)
+	}
+
+	block.SetLabels(to.labels)
+	walkAttributes(body, func(block *hclwrite.Block) bool {
+		return keepMoved && block.Type() == "moved"
+	}, func(attr *hclwrite.Attribute) {
+		attr.Expr().RenameVariablePrefix(from.traversal(), to.traversal())
+	})
+
+	return nil
+}
+
+// movedBlock returns a moved block from the from address to the to address.
+func movedBlock(from blockAddress, to blockAddress) *hclwrite.Block {
+	block := hclwrite.NewBlock("moved", nil)
+	block.Body().SetAttributeTraversal("from", absTraversal(from.traversal()))
+	block.Body().SetAttributeTraversal("to", absTraversal(to.traversal()))
+	return block
+}
+
+func absTraversal(steps []string) hcl.Traversal {
+	traversal := hcl.Traversal{hcl.TraverseRoot{Name: steps[0]}}
+	for _, step := range steps[1:] {
+		traversal = append(traversal, hcl.TraverseAttr{Name: step})
+	}
+	return traversal
+}
+
+// RenameBlocks renames resource, data and module blocks, e.g. from `aws_instance.old` to `aws_instance.new`.
+// Every reference to a renamed block is rewritten, and a moved block is added for a renamed resource or module
+// so that Terraform moves the existing object instead of replacing it.
+func (p HCLParser) RenameBlocks(file *hclwrite.File, renames map[string]string) (*hclwrite.File, error) {
+	file, err := reparseFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	froms := make([]string, 0, len(renames))
+	for from := range renames {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+
+	for _, fromAddress := range froms {
+		from, err := parseBlockAddress(fromAddress)
+		if err != nil {
+			return nil, err
+		}
+		to, err := parseBlockAddress(renames[fromAddress])
+		if err != nil {
+			return nil, err
+		}
+
+		if err := renameBlock(file.Body(), from, to, true); err != nil {
+			return nil, err
+		}
+		// Data sources have no state, so there is nothing to move.
+		if from.blockType != "data" {
+			file.Body().AppendBlock(movedBlock(from, to))
+			file.Body().AppendNewline()
+		}
+	}
+
+	if err := validateAddressBlocks(file.Body()); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,173 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestRenameBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		renames map[string]string
+		expect  string
+		wantErr bool
+	}{
+		{
+			name: "resource",
+			content: `resource "aws_instance" "old" {
+  ami = "ami-0c94855ba95c574c8"
+}
+output "ip" {
+  value = aws_instance.old.public_ip
+}
+output "name" {
+  value = "${aws_instance.old.id}-${aws_instance.old_name.id}"
+}
+moved {
+  from = aws_instance.older
+  to   = aws_instance.old
+}
+`,
+			renames: map[string]string{"aws_instance.old": "aws_instance.new"},
+			expect: `resource "aws_instance" "new" {
+  ami = "ami-0c94855ba95c574c8"
+}
+output "ip" {
+  value = aws_instance.new.public_ip
+}
+output "name" {
+  value = "${aws_instance.new.id}-${aws_instance.old_name.id}"
+}
+moved {
+  from = aws_instance.older
+  to   = aws_instance.old
+}
+moved {
+  from = aws_instance.old
+  to   = aws_instance.new
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "data and module",
+			content: `data "aws_ami" "old" {
+}
+module "old" {
+  source = "./modules/app"
+  ami    = data.aws_ami.old.id
+}
+output "id" {
+  value = module.old.id
+}
+`,
+			renames: map[string]string{
+				"data.aws_ami.old": "data.aws_ami.new",
+				"module.old":       "module.new",
+			},
+			expect: `data "aws_ami" "new" {
+}
+module "new" {
+  source = "./modules/app"
+  ami    = data.aws_ami.new.id
+}
+output "id" {
+  value = module.new.id
+}
+moved {
+  from = module.old
+  to   = module.new
+}
+`,
+			wantErr: false,
+		},
+		{
+			name: "not declared",
+			content: `resource "aws_instance" "web" {
+}
+`,
+			renames: map[string]string{"aws_instance.old": "aws_instance.new"},
+			wantErr: true,
+		},
+		{
+			name: "resource type is changed",
+			content: `resource "aws_instance" "old" {
+}
+`,
+			renames: map[string]string{"aws_instance.old": "aws_spot_instance_request.new"},
+			wantErr: true,
+		},
+		{
+			name: "already declared",
+			content: `resource "aws_instance" "old" {
+}
+resource "aws_instance" "new" {
+}
+`,
+			renames: map[string]string{"aws_instance.old": "aws_instance.new"},
+			wantErr: true,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(tt.content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.RenameBlocks(file, tt.renames)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RenameBlocks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
+
+func TestRenameBlocksAfterMerge(t *testing.T) {
+	base, diags := hclwrite.ParseConfig([]byte(`data "aws_ami" "ubuntu" {
+}
+resource "aws_instance" "web" {
+  ami = data.aws_ami.ubuntu.id
+}
+`), "base.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	overlay, diags := hclwrite.ParseConfig([]byte(`resource "aws_instance" "web" {
+  instance_type = "t3.large"
+}
+`), "overlay.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	parser := api.HCLParser{}
+	merged, err := parser.MergeFileBlocks(base, overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := parser.RenameBlocks(merged, map[string]string{"data.aws_ami.ubuntu": "data.aws_ami.noble"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `data "aws_ami" "noble" {
+}
+resource "aws_instance" "web" {
+  ami           = data.aws_ami.noble.id
+  instance_type = "t3.large"
+}
+`, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// hasNestedBlock reports whether body has a nested block of the type.
+func hasNestedBlock(body *hclwrite.Body, blockType string) bool {
+	for _, block := range body.Blocks() {
+		if block.Type() == blockType {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLabels reports whether the labels match the glob patterns one by one.
+func matchLabels(patterns []string, labels []string) (bool, error) {
+	if len(patterns) != len(labels) {
+		return false, nil
+	}
+	for i, pattern := range patterns {
+		matched, err := path.Match(pattern, labels[i])
+		if err != nil {
+			return false, fmt.Errorf("invalid label pattern %q: %w", pattern, err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resolveAll returns the attributes of every block whose labels match the labels of the path as glob patterns,
+// e.g. `resource.aws_*.*.tags`. Blocks without the attribute are skipped.
+// A provider with an alias is matched only when the segment after the labels is the alias,
+// e.g. `provider.*.west.region`, and a provider without an alias is matched by the path without it.
+func (p operationPath) resolveAll(body *hclwrite.Body) ([]pathTarget, error) {
+	if p.blockType == "locals" {
+		target, err := p.resolve(body, false)
+		if err != nil || target.attribute() == nil {
+			return nil, err
+		}
+		return []pathTarget{target}, nil
+	}
+
+	var targets []pathTarget
+	for _, block := range body.Blocks() {
+		if block.Type() != p.blockType {
+			continue
+		}
+		matched, err := matchLabels(p.labels, block.Labels())
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+
+		segments := p.segments
+		if alias := newBlockKey(block).alias; alias != "" {
+			if len(segments) < 2 || segments[0].index >= 0 || segments[0].name != alias {
+				continue
+			}
+			segments = segments[1:]
+		}
+
+		found := true
+		for _, segment := range segments[:len(segments)-1] {
+			if !hasNestedBlock(block.Body(), segment.name) {
+				found = false
+				break
+			}
+			nestedBlock, err := selectNestedBlock(block.Body(), segment)
+			if err != nil {
+				return nil, fmt.Errorf("path %q: %w", p.raw, err)
+			}
+			block = nestedBlock
+		}
+		target := pathTarget{body: block.Body(), segment: segments[len(segments)-1]}
+		if found && target.attribute() != nil {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+// applyReplacement writes the value of the source path, or the value expression, into every target.
+func applyReplacement(body *hclwrite.Body, replacement Replacement) error {
+	value := replacement.Value
+	switch {
+	case replacement.Source != "" && value != nil:
+		return fmt.Errorf("either source or value must be specified, not both")
+	case replacement.Source != "":
+		sourcePath, err := parseOperationPath(replacement.Source)
+		if err != nil {
+			return err
+		}
+		source, err := sourcePath.resolve(body, false)
+		if err != nil {
+			return err
+		}
+		attr := source.attribute()
+		if attr == nil {
+			return fmt.Errorf("attribute %q is not found", replacement.Source)
+		}
+		value = attr.Expr().BuildTokens(nil)
+	case value == nil:
+		return fmt.Errorf("either source or value must be specified")
+	}
+
+	for _, rawTarget := range replacement.Targets {
+		targetPath, err := parseOperationPath(rawTarget)
+		if err != nil {
+			return err
+		}
+		targets, err := targetPath.resolveAll(body)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("target %q matches no attribute", rawTarget)
+		}
+		for _, target := range targets {
+			target.body.SetAttributeRaw(target.segment.name, value)
+		}
+	}
+
+	return nil
+}
+
+// ApplyReplacements copies values into attributes at the target paths, such as writing the value of
+// `locals.account_id` into `resource.aws_*.*.account_id`. Labels of target paths can be glob patterns,
+// and only existing attributes are replaced.
+func (p HCLParser) ApplyReplacements(file *hclwrite.File, replacements []Replacement) (*hclwrite.File, error) {
+	for i, replacement := range replacements {
+		if err := applyReplacement(file.Body(), replacement); err != nil {
+			return nil, fmt.Errorf("replacement #%d: %w", i+1, err)
+		}
+	}
+	return file, nil
+}
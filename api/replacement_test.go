@@ -0,0 +1,147 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestApplyReplacements(t *testing.T) {
+	content := `locals {
+  account_id = "123456789012"
+}
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "19.0.0"
+}
+module "local" {
+  source = "./modules/local"
+}
+resource "aws_iam_role" "app" {
+  assume_role_policy {
+    account_id = "000000000000"
+  }
+}
+`
+
+	tests := []struct {
+		name         string
+		replacements []api.Replacement
+		expect       string
+		wantErr      bool
+	}{
+		{
+			name: "source and value",
+			replacements: []api.Replacement{
+				{Source: "locals.account_id", Targets: []string{"resource.aws_*.*.assume_role_policy.account_id"}},
+				{Value: valueTokens(t, `"5.1.0"`), Targets: []string{"module.*.version"}},
+			},
+			expect: `locals {
+  account_id = "123456789012"
+}
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.1.0"
+}
+module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "5.1.0"
+}
+module "local" {
+  source = "./modules/local"
+}
+resource "aws_iam_role" "app" {
+  assume_role_policy {
+    account_id = "123456789012"
+  }
+}
+`,
+		},
+		{
+			name: "target matches no attribute",
+			replacements: []api.Replacement{
+				{Value: valueTokens(t, `"5.1.0"`), Targets: []string{"module.local.version"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "source is not found",
+			replacements: []api.Replacement{
+				{Source: "locals.undefined", Targets: []string{"module.*.version"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "both source and value",
+			replacements: []api.Replacement{
+				{Source: "locals.account_id", Value: valueTokens(t, `"1"`), Targets: []string{"module.*.version"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(content), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := api.NewHCLParser().ApplyReplacements(file, tt.replacements)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyReplacements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
+
+func TestApplyReplacementsProviderAlias(t *testing.T) {
+	content := `provider "aws" {
+  region = "ap-northeast-1"
+}
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+provider "google" {
+  alias  = "west"
+  region = "us-west1"
+}
+`
+
+	file, diags := hclwrite.ParseConfig([]byte(content), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	result, err := api.NewHCLParser().ApplyReplacements(file, []api.Replacement{
+		{Source: "provider.aws.west.region", Targets: []string{"provider.google.west.region"}},
+		{Value: valueTokens(t, `"eu-west-1"`), Targets: []string{"provider.*.region"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `provider "aws" {
+  region = "eu-west-1"
+}
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+provider "google" {
+  alias  = "west"
+  region = "us-west-2"
+}
+`, string(hclwrite.Format(result.Bytes())))
+}
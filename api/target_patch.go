@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+var annotationLabelRegexp = regexp.MustCompile(`tfustomize:label:([\w-]+)`)
+
+// blockLabelAnnotations returns the labels annotated with `# tfustomize:label:<label>` in or right above the block.
+func blockLabelAnnotations(block *hclwrite.Block) []string {
+	var labels []string
+	for _, token := range block.BuildTokens(nil) {
+		if token.Type != hclsyntax.TokenComment {
+			continue
+		}
+		for _, match := range annotationLabelRegexp.FindAllSubmatch(token.Bytes, -1) {
+			if !slices.Contains(labels, string(match[1])) {
+				labels = append(labels, string(match[1]))
+			}
+		}
+	}
+	return labels
+}
+
+// appendLabelAnnotations writes label annotations into body so that they survive merging.
+func appendLabelAnnotations(body *hclwrite.Body, labels []string) {
+	for _, label := range labels {
+		body.AppendUnstructuredTokens(hclwrite.Tokens{
+			{Type: hclsyntax.TokenComment, Bytes: []byte("# tfustomize:label:" + label + "\n")},
+		})
+	}
+}
+
+// unionLabels returns labels followed by the ones in others which aren't in labels.
+func unionLabels(labels []string, others []string) []string {
+	result := slices.Clone(labels)
+	for _, label := range others {
+		if !slices.Contains(result, label) {
+			result = append(result, label)
+		}
+	}
+	return result
+}
+
+// recordLabelAnnotations keeps label annotations of the blocks in body, since merging them drops comments.
+func (p HCLParser) recordLabelAnnotations(body *hclwrite.Body) {
+	if p.labelAnnotations == nil {
+		return
+	}
+	for _, block := range body.Blocks() {
+		key := newBlockKey(block)
+		p.labelAnnotations[key] = unionLabels(p.labelAnnotations[key], blockLabelAnnotations(block))
+	}
+}
+
+// matches reports whether the block, which has the label annotations, is selected by the target.
+func (t Target) matches(block *hclwrite.Block, annotations []string) (bool, error) {
+	blockType := t.BlockType
+	if blockType == "" {
+		blockType = "resource"
+	}
+	if block.Type() != blockType {
+		return false, nil
+	}
+
+	// Providers are identified by their alias too, in the same way as newBlockKey, e.g. "aws.west".
+	joinedLabels := strings.Join(block.Labels(), ".")
+	if alias := newBlockKey(block).alias; alias != "" {
+		joinedLabels += "." + alias
+	}
+	if t.Address != "" {
+		matched, err := path.Match(t.Address, joinedLabels)
+		if err != nil {
+			return false, fmt.Errorf("invalid target address %q: %w", t.Address, err)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if t.LabelRegex != "" {
+		labelRegexp, err := regexp.Compile(t.LabelRegex)
+		if err != nil {
+			return false, fmt.Errorf("invalid target label_regex %q: %w", t.LabelRegex, err)
+		}
+		if !labelRegexp.MatchString(joinedLabels) {
+			return false, nil
+		}
+	}
+	if t.Annotation != "" && !slices.Contains(annotations, t.Annotation) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// ApplyTargetPatch merges the patch into every top-level block selected by the target,
+// with the same semantics as merging an overlay block.
+// Label annotations of the patched blocks are kept so that later patches can select them.
+func (p HCLParser) ApplyTargetPatch(file *hclwrite.File, target Target, patch *hclwrite.Block) (*hclwrite.File, error) {
+	matchedCount := 0
+
+	for _, block := range file.Body().Blocks() {
+		annotations := unionLabels(blockLabelAnnotations(block), p.labelAnnotations[newBlockKey(block)])
+		matched, err := target.matches(block, annotations)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			continue
+		}
+		matchedCount++
+
+		slog.Debug("patching the target block", "key", newBlockKey(block))
+		mergedBlock, err := mergeFuncFor(block.Type())(block, patch)
+		if err != nil {
+			return nil, err
+		}
+		annotatedBody := hclwrite.NewEmptyFile().Body()
+		appendLabelAnnotations(annotatedBody, unionLabels(annotations, blockLabelAnnotations(patch)))
+		annotatedBody.AppendUnstructuredTokens(mergedBlock.Body().BuildTokens(nil))
+		annotatedBlock, err := newBlockFromBody(mergedBlock.Type(), mergedBlock.Labels(), annotatedBody)
+		if err != nil {
+			return nil, err
+		}
+		replaceBlock(file.Body(), block, annotatedBlock)
+	}
+
+	if matchedCount == 0 {
+		slog.Warn("No block matches the target of the patch", "target", target)
+	}
+
+	return file, nil
+}
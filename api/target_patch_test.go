@@ -0,0 +1,252 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestApplyTargetPatch(t *testing.T) {
+	content := `resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+}
+resource "aws_instance" "batch" {
+  # tfustomize:label:backend
+  instance_type = "t3.micro"
+}
+resource "aws_s3_bucket" "web" {
+  bucket = "web"
+}
+data "aws_instance" "web" {
+  instance_id = "i-abcd1234"
+}
+`
+	patch := `patch {
+  monitoring = true
+}
+`
+
+	tests := []struct {
+		name    string
+		target  api.Target
+		expect  string
+		wantErr bool
+	}{
+		{
+			name:   "address",
+			target: api.Target{Address: "aws_instance.*"},
+			expect: `resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+  monitoring    = true
+}
+resource "aws_instance" "batch" {
+  # tfustomize:label:backend
+  instance_type = "t3.micro"
+  monitoring    = true
+}
+resource "aws_s3_bucket" "web" {
+  bucket = "web"
+}
+data "aws_instance" "web" {
+  instance_id = "i-abcd1234"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:   "label regex",
+			target: api.Target{LabelRegex: `\.web$`},
+			expect: `resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+  monitoring    = true
+}
+resource "aws_instance" "batch" {
+  # tfustomize:label:backend
+  instance_type = "t3.micro"
+}
+resource "aws_s3_bucket" "web" {
+  bucket     = "web"
+  monitoring = true
+}
+data "aws_instance" "web" {
+  instance_id = "i-abcd1234"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:   "annotation and block type",
+			target: api.Target{BlockType: "resource", Annotation: "backend"},
+			expect: `resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+}
+resource "aws_instance" "batch" {
+  # tfustomize:label:backend
+  instance_type = "t3.micro"
+  monitoring    = true
+}
+resource "aws_s3_bucket" "web" {
+  bucket = "web"
+}
+data "aws_instance" "web" {
+  instance_id = "i-abcd1234"
+}
+`,
+			wantErr: false,
+		},
+		{
+			name:    "invalid label regex",
+			target:  api.Target{LabelRegex: `(`},
+			wantErr: true,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			patchFile, diags := hclwrite.ParseConfig([]byte(patch), "tfustomization.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.ApplyTargetPatch(file, tt.target, patchFile.Body().Blocks()[0])
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApplyTargetPatch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				assert.Equal(t, tt.expect, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"))
+			}
+		})
+	}
+}
+
+func TestApplyTargetPatchAfterMerge(t *testing.T) {
+	base := `resource "aws_instance" "batch" {
+  # tfustomize:label:backend
+  instance_type = "t3.micro"
+}
+`
+	overlay := `resource "aws_instance" "batch" {
+  instance_type = "t3.large"
+}
+`
+	patch := `patch {
+  monitoring = true
+}
+`
+
+	parser := api.NewHCLParser()
+
+	baseHCL, diags := hclwrite.ParseConfig([]byte(base), "base.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	overlayHCL, diags := hclwrite.ParseConfig([]byte(overlay), "overlay.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	patchFile, diags := hclwrite.ParseConfig([]byte(patch), "tfustomization.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	merged, err := parser.MergeFileBlocks(baseHCL, overlayHCL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A plain merge doesn't write the annotation into the result.
+	assert.Equal(t, `resource "aws_instance" "batch" {
+  instance_type = "t3.large"
+}
+`, regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(merged.Bytes())), "\n"))
+
+	result, err := parser.ApplyTargetPatch(merged, api.Target{Annotation: "backend"}, patchFile.Body().Blocks()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `resource "aws_instance" "batch" {
+  # tfustomize:label:backend
+  instance_type = "t3.large"
+  monitoring    = true
+}
+`, strings.TrimLeft(regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"), "\n"))
+}
+
+func TestApplyTargetPatchProviderAlias(t *testing.T) {
+	content := `provider "aws" {
+  region = "ap-northeast-1"
+}
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+`
+	patch := `patch {
+  max_retries = 5
+}
+`
+
+	tests := []struct {
+		name   string
+		target api.Target
+		expect string
+	}{
+		{
+			name:   "aliased provider",
+			target: api.Target{BlockType: "provider", Address: "aws.west"},
+			expect: `provider "aws" {
+  region = "ap-northeast-1"
+}
+provider "aws" {
+  alias       = "west"
+  max_retries = 5
+  region      = "us-west-2"
+}
+`,
+		},
+		{
+			name:   "default provider",
+			target: api.Target{BlockType: "provider", Address: "aws"},
+			expect: `provider "aws" {
+  max_retries = 5
+  region      = "ap-northeast-1"
+}
+provider "aws" {
+  alias  = "west"
+  region = "us-west-2"
+}
+`,
+		},
+	}
+
+	parser := api.HCLParser{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(content), "main.tf", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+			patchFile, diags := hclwrite.ParseConfig([]byte(patch), "tfustomization.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := parser.ApplyTargetPatch(file, tt.target, patchFile.Body().Blocks()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expect, strings.TrimLeft(regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(result.Bytes())), "\n"), "\n"))
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// mergeTerraformBlock merges terraform settings blocks.
+// Unlike other blocks, nested blocks are merged by their type and labels instead of being appended:
+//   - required_providers entries are deep merged by the provider local name,
+//   - a backend is merged when the type is the same, and replaced by the overlay otherwise,
+//   - a backend and a cloud block replace each other,
+//   - cloud and provider_meta blocks are merged, and experiments are unioned.
+func mergeTerraformBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwrite.Block, error) {
+	resultBlock := hclwrite.NewBlock(baseBlock.Type(), baseBlock.Labels())
+	if err := mergeSettingsBody(resultBlock.Body(), baseBlock.Body(), overlayBlock.Body(), false); err != nil {
+		return nil, fmt.Errorf("terraform: %w", err)
+	}
+	return resultBlock, nil
+}
+
+// mergeSettingsBody writes the merged attributes and nested blocks of base and overlay into result.
+// Attributes of the overlay replace the base ones. When deepMerge is true, which is the case of
+// required_providers, attributes are deep merged if both of them are objects.
+func mergeSettingsBody(result *hclwrite.Body, base *hclwrite.Body, overlay *hclwrite.Body, deepMerge bool) error {
+	tmpAttributes := map[string]hclwrite.Tokens{}
+
+	for name, attribute := range base.Attributes() {
+		tmpAttributes[name] = attribute.Expr().BuildTokens(nil)
+	}
+	for name, attribute := range overlay.Attributes() {
+		overlayTokens := attribute.Expr().BuildTokens(nil)
+		baseTokens, ok := tmpAttributes[name]
+		if !ok || hasBaseReference(overlayTokens) {
+			substituted, err := substituteBase(baseTokens, overlayTokens)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			tmpAttributes[name] = substituted
+			continue
+		}
+
+		if name == "experiments" {
+			if merged, ok := unionListTokens(baseTokens, overlayTokens); ok {
+				tmpAttributes[name] = merged
+				continue
+			}
+		}
+		if deepMerge {
+			if merged, ok := mergeObjectTokens(baseTokens, overlayTokens); ok {
+				tmpAttributes[name] = merged
+				continue
+			}
+		}
+		tmpAttributes[name] = overlayTokens
+	}
+
+	sortedNames := make([]string, 0, len(tmpAttributes))
+	for name := range tmpAttributes {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		result.SetAttributeRaw(name, tmpAttributes[name])
+	}
+
+	nestedBlocks := newBlockSet()
+	for _, block := range base.Blocks() {
+		key := settingsBlockKey(block)
+		if merged, ok := nestedBlocks.get(key); ok {
+			mergedBlock, err := mergeSettingsBlock(merged, block)
+			if err != nil {
+				return err
+			}
+			block = mergedBlock
+		}
+		nestedBlocks.set(key, block)
+	}
+	for _, block := range overlay.Blocks() {
+		key := settingsBlockKey(block)
+		baseNestedBlock, ok := nestedBlocks.get(key)
+		if !ok || block.Type() != baseNestedBlock.Type() || !slices.Equal(baseNestedBlock.Labels(), block.Labels()) {
+			if ok {
+				slog.Debug("backend type is changed, so the backend is replaced", "base", newBlockKey(baseNestedBlock), "overlay", newBlockKey(block))
+			}
+			if err := checkNoBaseReference(block); err != nil {
+				return err
+			}
+			nestedBlocks.set(key, block)
+			continue
+		}
+		mergedBlock, err := mergeSettingsBlock(baseNestedBlock, block)
+		if err != nil {
+			return err
+		}
+		nestedBlocks.set(key, mergedBlock)
+	}
+
+	for _, block := range nestedBlocks.ordered() {
+		result.AppendNewline()
+		result.AppendBlock(block)
+	}
+
+	return nil
+}
+
+func mergeSettingsBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwrite.Block, error) {
+	resultBlock := hclwrite.NewBlock(overlayBlock.Type(), overlayBlock.Labels())
+	deepMerge := overlayBlock.Type() == "required_providers"
+	if err := mergeSettingsBody(resultBlock.Body(), baseBlock.Body(), overlayBlock.Body(), deepMerge); err != nil {
+		return nil, fmt.Errorf("%s: %w", newBlockKey(overlayBlock), err)
+	}
+	return resultBlock, nil
+}
+
+// settingsBlockKey identifies a block nested in a terraform block.
+// Only one backend or cloud block is allowed, so they share a key regardless of the backend type.
+func settingsBlockKey(block *hclwrite.Block) blockKey {
+	if block.Type() == "backend" || block.Type() == "cloud" {
+		return blockKey{blockType: "backend"}
+	}
+	return newBlockKey(block)
+}
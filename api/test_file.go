@@ -0,0 +1,174 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"golang.org/x/exp/slices"
+)
+
+const testFileExtension = ".tftest.hcl"
+
+// testMergedBlockTypes are the top-level block types of test files which are merged by their keys.
+// Other blocks, such as override_resource, are appended.
+var testMergedBlockTypes = []string{
+	"mock_provider",
+	"provider",
+	"run",
+	"test",
+	"variables",
+}
+
+// runMergedBlockTypes are the nested block types of run blocks which are merged attribute-wise.
+// Other nested blocks, such as assert, are appended.
+var runMergedBlockTypes = []string{
+	"module",
+	"variables",
+}
+
+func isTestFile(name string) bool {
+	return strings.HasSuffix(name, testFileExtension)
+}
+
+// CollectTestFilePaths returns a list of .tftest.hcl files in the given paths.
+// For a directory, the files in its tests directory are also returned, as terraform test does.
+func (p HCLParser) CollectTestFilePaths(baseDir string, paths []string) ([]string, error) {
+	var dirs []string
+	for _, path := range paths {
+		fullPath := filepath.Join(baseDir, path)
+		fileInfo, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, path)
+		if testsDir, err := os.Stat(filepath.Join(fullPath, "tests")); fileInfo.IsDir() && err == nil && testsDir.IsDir() {
+			dirs = append(dirs, filepath.Join(path, "tests"))
+		}
+	}
+
+	return collectFilePaths(baseDir, dirs, isTestFile)
+}
+
+// mergeRunBlock merges run blocks. Nested variables and module blocks are merged attribute-wise
+// and put first, and the other nested blocks such as assert are appended.
+func mergeRunBlock(baseBlock *hclwrite.Block, overlayBlock *hclwrite.Block) (*hclwrite.Block, error) {
+	merged := map[string]*hclwrite.Block{}
+	for _, block := range []*hclwrite.Block{baseBlock, overlayBlock} {
+		for _, nestedBlock := range block.Body().Blocks() {
+			if !slices.Contains(runMergedBlockTypes, nestedBlock.Type()) {
+				continue
+			}
+			block.Body().RemoveBlock(nestedBlock)
+			if existing, ok := merged[nestedBlock.Type()]; ok {
+				mergedBlock, err := mergeBlock(existing, nestedBlock)
+				if err != nil {
+					return nil, err
+				}
+				nestedBlock = mergedBlock
+			}
+			merged[nestedBlock.Type()] = nestedBlock
+		}
+	}
+
+	resultBlock, err := mergeBlock(baseBlock, overlayBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the block again to put the merged blocks before the appended blocks.
+	// The attributes are kept as mergeBlock writes them.
+	appendedBlocks := resultBlock.Body().Blocks()
+	for _, block := range appendedBlocks {
+		resultBlock.Body().RemoveBlock(block)
+	}
+	tokens := resultBlock.Body().BuildTokens(nil)
+	for len(tokens) > 0 && tokens[len(tokens)-1].Type == hclsyntax.TokenNewline && (len(tokens) == 1 || tokens[len(tokens)-2].Type == hclsyntax.TokenNewline) {
+		tokens = tokens[:len(tokens)-1]
+	}
+	attributesBody := hclwrite.NewEmptyFile().Body()
+	attributesBody.AppendUnstructuredTokens(tokens)
+	runBlock, err := newBlockFromBody(resultBlock.Type(), resultBlock.Labels(), attributesBody)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, blockType := range runMergedBlockTypes {
+		if block, ok := merged[blockType]; ok {
+			runBlock.Body().AppendNewline()
+			runBlock.Body().AppendBlock(block)
+		}
+	}
+	for _, block := range appendedBlocks {
+		runBlock.Body().AppendNewline()
+		runBlock.Body().AppendBlock(block)
+	}
+
+	return runBlock, nil
+}
+
+// mergeTestFile merges the blocks of a test file into the blocks of another test file of the same name.
+// run blocks are merged by their labels and keep their order, since it's the order to run them.
+func mergeTestFile(base *blockSet, overlay *hclwrite.File) error {
+	for _, block := range overlay.Body().Blocks() {
+		key := newBlockKey(block)
+		if !slices.Contains(testMergedBlockTypes, block.Type()) {
+			// The other blocks are not identified, so give them unique keys to append them.
+			key.arguments = strconv.Itoa(len(base.keys))
+			base.set(key, block)
+			continue
+		}
+		existing, ok := base.get(key)
+		if !ok {
+			base.set(key, block)
+			continue
+		}
+
+		mergeFunc := mergeBlock
+		if block.Type() == "run" {
+			mergeFunc = mergeRunBlock
+		}
+		merged, err := mergeFunc(existing, block)
+		if err != nil {
+			return err
+		}
+		base.set(key, merged)
+	}
+	return nil
+}
+
+// MergeTestFiles merges .tftest.hcl files of the same name in order, and returns the results keyed by the names.
+func (p HCLParser) MergeTestFiles(paths []string) (map[string]*hclwrite.File, error) {
+	testFiles := map[string]*blockSet{}
+
+	for _, path := range paths {
+		file, err := p.ReadHCLFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		name := filepath.Base(path)
+		if testFiles[name] == nil {
+			testFiles[name] = newBlockSet()
+		}
+		if err := mergeTestFile(testFiles[name], file); err != nil {
+			return nil, err
+		}
+	}
+
+	results := map[string]*hclwrite.File{}
+	for name, blocks := range testFiles {
+		file := hclwrite.NewEmptyFile()
+		for i, block := range blocks.ordered() {
+			if i > 0 {
+				file.Body().AppendNewline()
+			}
+			file.Body().AppendBlock(block)
+		}
+		results[name] = file
+	}
+	return results, nil
+}
@@ -0,0 +1,81 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestCollectTestFilePaths(t *testing.T) {
+	got, err := api.NewHCLParser().CollectTestFilePaths("../test/test_files", []string{"./base", "./overlay/tests/main.tftest.hcl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{
+		"../test/test_files/base/tests/base_only.tftest.hcl",
+		"../test/test_files/base/tests/main.tftest.hcl",
+		"../test/test_files/overlay/tests/main.tftest.hcl",
+	}, got)
+}
+
+func TestMergeTestFiles(t *testing.T) {
+	results, err := api.NewHCLParser().MergeTestFiles([]string{
+		"../test/test_files/base/tests/base_only.tftest.hcl",
+		"../test/test_files/base/tests/main.tftest.hcl",
+		"../test/test_files/overlay/tests/main.tftest.hcl",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, `run "base_only" {
+  command = plan
+}
+`, string(hclwrite.Format(results["base_only.tftest.hcl"].Bytes())))
+	assert.Equal(t, `variables {
+  env           = "production"
+  instance_type = "t3.micro"
+}
+
+provider "aws" {
+  region = "ap-northeast-1"
+}
+
+run "plan" {
+  command = plan
+
+  variables {
+    name = "web-production"
+    tier = "frontend"
+  }
+
+  assert {
+    condition     = aws_instance.web.instance_type == var.instance_type
+    error_message = "instance type is wrong"
+  }
+
+  assert {
+    condition     = aws_instance.web.monitoring
+    error_message = "monitoring is disabled"
+  }
+}
+
+run "apply" {
+  assert {
+    condition     = aws_instance.web.id != ""
+    error_message = "instance is not created"
+  }
+}
+
+mock_provider "aws" {
+  alias = "mock"
+}
+
+run "production_only" {
+  command = plan
+}
+`, string(hclwrite.Format(results["main.tftest.hcl"].Bytes())))
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// tfvarsValue is a value of a variable in a .tfvars file, which is evaluated after it's merged with the base value.
+type tfvarsValue struct {
+	value cty.Value
+	expr  hclsyntax.Expression
+}
+
+// readTfvarsFile returns the values of a .tfvars or .tfvars.json file keyed by variable names.
+func readTfvarsFile(path string) (map[string]tfvarsValue, error) {
+	values := map[string]tfvarsValue{}
+
+	if strings.HasSuffix(path, ".json") {
+		value, err := readDataFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !value.Type().IsObjectType() {
+			return nil, fmt.Errorf("%s must be an object", path)
+		}
+		for name, attribute := range value.AsValueMap() {
+			values[name] = tfvarsValue{value: attribute}
+		}
+		return values, nil
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+	body := file.Body.(*hclsyntax.Body)
+	if len(body.Blocks) != 0 {
+		return nil, fmt.Errorf("%s must not have blocks", path)
+	}
+	for name, attr := range body.Attributes {
+		values[name] = tfvarsValue{expr: attr.Expr}
+	}
+	return values, nil
+}
+
+// hasBaseVariable reports whether the expression refers to tfustomize_base.
+func hasBaseVariable(expr hclsyntax.Expression) bool {
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() == baseReference {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate returns the value merged into the base value. Objects are deep merged unless the expression
+// refers to the base value as tfustomize_base, in which case the result of the expression replaces it.
+func (v tfvarsValue) evaluate(base cty.Value, hasBase bool) (cty.Value, error) {
+	value := v.value
+	if v.expr != nil {
+		referred := hasBaseVariable(v.expr)
+		if referred && !hasBase {
+			return cty.NilVal, fmt.Errorf("%s is used but there is no base value", baseReference)
+		}
+
+		ctx := &hcl.EvalContext{Functions: configFunctions()}
+		if referred {
+			ctx.Variables = map[string]cty.Value{baseReference: base}
+		}
+		evaluated, diags := v.expr.Value(ctx)
+		if diags.HasErrors() {
+			return cty.NilVal, fmt.Errorf(diags.Error())
+		}
+		if referred {
+			return evaluated, nil
+		}
+		value = evaluated
+	}
+
+	if !hasBase {
+		return value, nil
+	}
+	return deepMergeValues(base, value), nil
+}
+
+func isMergeableObject(value cty.Value) bool {
+	return value.IsKnown() && !value.IsNull() && (value.Type().IsObjectType() || value.Type().IsMapType())
+}
+
+// deepMergeValues merges objects recursively, and the overlay wins on conflicts. Any other value is replaced.
+func deepMergeValues(base cty.Value, overlay cty.Value) cty.Value {
+	if !isMergeableObject(base) || !isMergeableObject(overlay) {
+		return overlay
+	}
+
+	merged := base.AsValueMap()
+	if merged == nil {
+		merged = map[string]cty.Value{}
+	}
+	for name, value := range overlay.AsValueMap() {
+		if baseValue, ok := merged[name]; ok {
+			value = deepMergeValues(baseValue, value)
+		}
+		merged[name] = value
+	}
+	return cty.ObjectVal(merged)
+}
+
+// MergeTfvarsFiles merges .tfvars and .tfvars.json files attribute-wise. A value in a later file replaces
+// the value in earlier files, and objects are deep merged. A value can refer to the replaced value as
+// tfustomize_base, e.g. `concat(tfustomize_base, ["subnet-b"])`.
+// Values are evaluated into literals, since Terraform doesn't accept function calls in .tfvars files.
+func (p HCLParser) MergeTfvarsFiles(paths []string) (*hclwrite.File, error) {
+	merged := map[string]cty.Value{}
+
+	for _, path := range paths {
+		values, err := readTfvarsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range values {
+			base, hasBase := merged[name]
+			evaluated, err := value.evaluate(base, hasBase)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+			merged[name] = evaluated
+		}
+	}
+
+	names := make([]string, 0, len(merged))
+	for name := range merged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	file := hclwrite.NewEmptyFile()
+	for _, name := range names {
+		file.Body().SetAttributeValue(name, merged[name])
+	}
+	return file, nil
+}
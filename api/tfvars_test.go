@@ -0,0 +1,63 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestCollectTfvarsFilePaths(t *testing.T) {
+	got, err := api.NewHCLParser().CollectTfvarsFilePaths("../test", []string{"./tfvars", "./collect_hcl_file_paths"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"../test/tfvars/common.tfvars", "../test/tfvars/prod.tfvars", "../test/tfvars/prod.tfvars.json"}, got)
+}
+
+func TestMergeTfvarsFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		expect  string
+		wantErr bool
+	}{
+		{
+			name:  "layered files",
+			paths: []string{"../test/tfvars/common.tfvars", "../test/tfvars/prod.tfvars", "../test/tfvars/prod.tfvars.json"},
+			expect: `enable_monitoring = true
+instance_type     = "m5.large"
+settings = {
+  log = {
+    level     = "warn"
+    retention = 7
+  }
+}
+subnet_ids = ["subnet-a", "subnet-b"]
+tags = {
+  Environment = "production"
+  Team        = "platform"
+}
+`,
+		},
+		{
+			name:    "base reference without base",
+			paths:   []string{"../test/tfvars/prod.tfvars"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := api.NewHCLParser().MergeTfvarsFiles(tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MergeTfvarsFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// SetVariableDefaults overrides the default of every variable block named in defaults.
+// It's an error when a name is not a declared variable.
+func (p HCLParser) SetVariableDefaults(file *hclwrite.File, defaults map[string]hclwrite.Tokens) (*hclwrite.File, error) {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		block := file.Body().FirstMatchingBlock("variable", []string{name})
+		if block == nil {
+			errs = append(errs, fmt.Errorf("variable %q has a default in variable_defaults but it is not declared", name))
+			continue
+		}
+		block.Body().SetAttributeRaw("default", defaults[name])
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
@@ -0,0 +1,93 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestSetVariableDefaults(t *testing.T) {
+	content := `variable "instance_type" {
+  type    = string
+  default = "t3.micro"
+}
+variable "subnet_ids" {
+  type = list(string)
+}
+`
+
+	tests := []struct {
+		name     string
+		defaults map[string]hclwrite.Tokens
+		expect   string
+		wantErr  bool
+	}{
+		{
+			name: "override and add defaults",
+			defaults: map[string]hclwrite.Tokens{
+				"instance_type": valueTokens(t, `"m5.large"`),
+				"subnet_ids":    valueTokens(t, `["subnet-0123456789abcdef0"]`),
+			},
+			expect: `variable "instance_type" {
+  type    = string
+  default = "m5.large"
+}
+variable "subnet_ids" {
+  type    = list(string)
+  default = ["subnet-0123456789abcdef0"]
+}
+`,
+		},
+		{
+			name: "undeclared variable",
+			defaults: map[string]hclwrite.Tokens{
+				"undeclared": valueTokens(t, `"value"`),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(content), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := api.NewHCLParser().SetVariableDefaults(file, tt.defaults)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetVariableDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
+
+func TestVariableDefaultsTokens(t *testing.T) {
+	conf, err := api.LoadConfig("../test/variable_defaults/tfustomization.hcl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults, err := conf.VariableDefaults.Tokens("../test/variable_defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, defaults, 2)
+	assert.Equal(t, `"m5.large"`, strings.TrimSpace(string(defaults["instance_type"].Bytes())))
+	assert.Equal(t, `["subnet-0123456789abcdef0"]`, strings.TrimSpace(string(defaults["subnet_ids"].Bytes())))
+
+	tfvarsOnly := api.VariableDefaults{Tfvars: "production.tfvars"}
+	defaults, err = tfvarsOnly.Tokens("../test/variable_defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `"t3.large"`, strings.TrimSpace(string(defaults["instance_type"].Bytes())))
+}
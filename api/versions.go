@@ -0,0 +1,118 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var regexpRegistrySourcePart = regexp.MustCompile(`^[\w.-]+$`)
+
+// isRegistrySource reports whether a module source is a module registry address, such as
+// `terraform-aws-modules/vpc/aws` or `app.terraform.io/example/vpc/aws`, which accepts a version.
+// Local paths, shorthands such as `github.com/org/repo` and URLs such as `git::https://...` are not.
+func isRegistrySource(source string) bool {
+	if isLocalPath(source) || strings.Contains(source, "::") || strings.Contains(source, "://") {
+		return false
+	}
+	address, _, _ := strings.Cut(source, "//")
+	parts := strings.Split(address, "/")
+	if len(parts) != 3 && len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if !regexpRegistrySourcePart.MatchString(part) {
+			return false
+		}
+	}
+	// A namespace has no dots, so `github.com/org/repo` is a GitHub shorthand, while
+	// the hostname of a private registry must have a dot.
+	return (len(parts) == 3) != strings.Contains(parts[0], ".")
+}
+
+// setModuleVersion sets the version of every module block whose source matches the glob pattern.
+// Modules whose source isn't a registry address are skipped, since Terraform rejects a version for them.
+// It returns the number of the module blocks.
+func setModuleVersion(body *hclwrite.Body, source string, version string) (int, error) {
+	count := 0
+	for _, block := range body.Blocks() {
+		if block.Type() != "module" {
+			continue
+		}
+		blockSource, ok := attributeStringValue(block.Body(), "source")
+		if !ok {
+			continue
+		}
+		matched, err := path.Match(source, blockSource)
+		if err != nil {
+			return 0, fmt.Errorf("invalid module source pattern %q: %w", source, err)
+		}
+		if !matched {
+			continue
+		}
+		if !isRegistrySource(blockSource) {
+			slog.Warn("The module source is not a registry address, so the version is not set", "source", blockSource)
+			continue
+		}
+		block.Body().SetAttributeValue("version", cty.StringVal(version))
+		count++
+	}
+	return count, nil
+}
+
+// setProviderVersion sets the version of the provider in required_providers blocks.
+// It returns the number of the required_providers blocks which have the provider.
+func setProviderVersion(body *hclwrite.Body, name string, version string) int {
+	count := 0
+	for _, block := range body.Blocks() {
+		if block.Type() != "terraform" {
+			continue
+		}
+		for _, requiredProviders := range block.Body().Blocks() {
+			if requiredProviders.Type() != "required_providers" {
+				continue
+			}
+			attr := requiredProviders.Body().GetAttribute(name)
+			if attr == nil {
+				continue
+			}
+			count++
+
+			// A version constraint can also be written as a string instead of an object, e.g. `aws = "~> 5.0"`.
+			versionTokens := hclwrite.TokensForValue(cty.ObjectVal(map[string]cty.Value{"version": cty.StringVal(version)}))
+			if merged, ok := mergeObjectTokens(attr.Expr().BuildTokens(nil), versionTokens); ok {
+				requiredProviders.Body().SetAttributeRaw(name, merged)
+			} else {
+				requiredProviders.Body().SetAttributeValue(name, cty.StringVal(version))
+			}
+		}
+	}
+	return count
+}
+
+// SetVersions sets the version of module blocks whose source matches a glob pattern,
+// and the version of providers in required_providers blocks.
+func (p HCLParser) SetVersions(file *hclwrite.File, versions Versions) (*hclwrite.File, error) {
+	for _, module := range versions.Modules {
+		count, err := setModuleVersion(file.Body(), module.Source, module.Version)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			slog.Warn("No module block matches the source", "source", module.Source)
+		}
+	}
+
+	for _, provider := range versions.Providers {
+		if setProviderVersion(file.Body(), provider.Name, provider.Version) == 0 {
+			slog.Warn("The provider is not found in required_providers", "name", provider.Name)
+		}
+	}
+
+	return file, nil
+}
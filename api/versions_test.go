@@ -0,0 +1,215 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/assert"
+	"github.com/tk3fftk/tfustomize/api"
+)
+
+func TestSetVersions(t *testing.T) {
+	content := `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+    random = "~> 3.0"
+  }
+}
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+module "vpc_secondary" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "19.0.0"
+}
+module "local" {
+  source = "./modules/local"
+}
+module "git" {
+  source = "git::https://example.com/vpc.git"
+}
+module "github" {
+  source = "github.com/example/vpc"
+}
+module "private" {
+  source = "app.terraform.io/example/vpc/aws"
+}
+`
+
+	tests := []struct {
+		name     string
+		versions api.Versions
+		expect   string
+		wantErr  bool
+	}{
+		{
+			name: "modules and providers",
+			versions: api.Versions{
+				Modules: []api.ModuleVersion{
+					{Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0"},
+				},
+				Providers: []api.ProviderVersion{
+					{Name: "aws", Version: "~> 5.0"},
+					{Name: "random", Version: "~> 3.5"},
+					{Name: "google", Version: "~> 5.0"},
+				},
+			},
+			expect: `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    random = "~> 3.5"
+  }
+}
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.1.0"
+}
+module "vpc_secondary" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.1.0"
+}
+module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "19.0.0"
+}
+module "local" {
+  source = "./modules/local"
+}
+module "git" {
+  source = "git::https://example.com/vpc.git"
+}
+module "github" {
+  source = "github.com/example/vpc"
+}
+module "private" {
+  source = "app.terraform.io/example/vpc/aws"
+}
+`,
+		},
+		{
+			name: "glob pattern",
+			versions: api.Versions{
+				Modules: []api.ModuleVersion{
+					{Source: "terraform-aws-modules/*/aws", Version: "6.0.0"},
+				},
+			},
+			expect: `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+    random = "~> 3.0"
+  }
+}
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "6.0.0"
+}
+module "vpc_secondary" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "6.0.0"
+}
+module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "6.0.0"
+}
+module "local" {
+  source = "./modules/local"
+}
+module "git" {
+  source = "git::https://example.com/vpc.git"
+}
+module "github" {
+  source = "github.com/example/vpc"
+}
+module "private" {
+  source = "app.terraform.io/example/vpc/aws"
+}
+`,
+		},
+		{
+			name: "sources which are not registry addresses",
+			versions: api.Versions{
+				Modules: []api.ModuleVersion{
+					{Source: "./modules/local", Version: "1.0.0"},
+					{Source: "git::https://example.com/vpc.git", Version: "1.0.0"},
+					{Source: "github.com/example/vpc", Version: "1.0.0"},
+					{Source: "app.terraform.io/example/vpc/aws", Version: "1.0.0"},
+				},
+			},
+			expect: `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+    random = "~> 3.0"
+  }
+}
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+module "vpc_secondary" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+module "eks" {
+  source  = "terraform-aws-modules/eks/aws"
+  version = "19.0.0"
+}
+module "local" {
+  source = "./modules/local"
+}
+module "git" {
+  source = "git::https://example.com/vpc.git"
+}
+module "github" {
+  source = "github.com/example/vpc"
+}
+module "private" {
+  source  = "app.terraform.io/example/vpc/aws"
+  version = "1.0.0"
+}
+`,
+		},
+		{
+			name: "invalid pattern",
+			versions: api.Versions{
+				Modules: []api.ModuleVersion{
+					{Source: "[", Version: "6.0.0"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, diags := hclwrite.ParseConfig([]byte(content), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatal(diags)
+			}
+
+			result, err := api.NewHCLParser().SetVersions(file, tt.versions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			assert.Equal(t, tt.expect, string(hclwrite.Format(result.Bytes())))
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/spf13/cobra"
@@ -19,6 +20,10 @@ var regexpFormatNewLines = regexp.MustCompile(`\n{2,}`)
 var print bool
 var outputDir string
 var outputFile string
+var backendConfig string
+var vars []string
+var localizeModules bool
+var tofu bool
 
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
@@ -39,7 +44,19 @@ The command concatenates files specified in the resources and patches blocks, me
 			return err
 		}
 
-		conf, _ := api.LoadConfig(tfustomizationPath)
+		varValues := map[string]string{}
+		for _, v := range vars {
+			name, value, ok := strings.Cut(v, "=")
+			if !ok {
+				return fmt.Errorf("--var must be in the form of key=value: %q", v)
+			}
+			varValues[name] = value
+		}
+
+		conf, err := api.LoadConfig(tfustomizationPath, varValues)
+		if err != nil {
+			return err
+		}
 		if len(conf.Resources.Paths) == 0 {
 			err := fmt.Errorf("tfustomization.hcl must have a resources block")
 			return err
@@ -48,6 +65,15 @@ The command concatenates files specified in the resources and patches blocks, me
 		slog.Debug("tfustomization.hcl is loaded", "path", tfustomizationPath, "conf", conf)
 
 		parser := api.NewHCLParser()
+		outputDirPath := filepath.Join(baseConfDir, outputDir)
+		// Relative paths are always rewritten, so that the result refers to the same files as the inputs.
+		parser.OutputDir = outputDirPath
+		if conf.Assets != nil {
+			parser.Assets, err = parser.CollectAssets(filepath.Dir(tfustomizationPath), conf.Assets.Paths)
+			if err != nil {
+				return err
+			}
+		}
 
 		basePaths, err := parser.CollectHCLFilePaths(filepath.Dir(tfustomizationPath), conf.Resources.Paths)
 		if err != nil {
@@ -57,27 +83,185 @@ The command concatenates files specified in the resources and patches blocks, me
 		if err != nil {
 			return err
 		}
+		if len(conf.Placeholders) != 0 {
+			baseHCLFile = parser.ReplacePlaceholders(baseHCLFile, conf.Placeholders)
+		}
 
 		overlayPaths, err := parser.CollectHCLFilePaths(filepath.Dir(tfustomizationPath), conf.Patches.Paths)
 		if err != nil {
 			return err
 		}
-		overlayHCLFile, err := parser.ConcatFiles(overlayPaths)
+		patchHCLFile, err := parser.ConcatFiles(overlayPaths)
 		if err != nil {
 			return err
 		}
+		// Generated locals come first, so that the overlay files can override them.
+		overlayHCLFile := hclwrite.NewEmptyFile()
+		if len(conf.LocalsGenerators) != 0 {
+			generatedLocals, err := api.GenerateLocals(filepath.Dir(tfustomizationPath), conf.LocalsGenerators)
+			if err != nil {
+				return err
+			}
+			overlayHCLFile.Body().AppendBlock(generatedLocals)
+		}
+		for _, block := range patchHCLFile.Body().Blocks() {
+			overlayHCLFile.Body().AppendBlock(block)
+		}
+		inlineHCLFile, err := parser.ConcatBlocks(conf.Patches.Inline, filepath.Dir(tfustomizationPath))
+		if err != nil {
+			return err
+		}
+		for _, block := range inlineHCLFile.Body().Blocks() {
+			overlayHCLFile.Body().AppendBlock(block)
+		}
 
 		_, err = parser.MergeFileBlocks(baseHCLFile, overlayHCLFile)
 		if err != nil {
 			return err
 		}
 
+		var backendConfigResult string
+		if backendConfig != "" && conf.Backend == nil {
+			return fmt.Errorf("--backend-config requires a backend block in %s", tfustomizationPath)
+		}
+		if conf.Backend != nil {
+			backendBlock, err := conf.Backend.BuildBlock()
+			if err != nil {
+				return err
+			}
+			baseHCLFile, err = parser.SetBackend(baseHCLFile, backendBlock)
+			if err != nil {
+				return err
+			}
+			if backendConfig != "" {
+				// Keep only the backend type in the configuration and pass the merged settings via -backend-config.
+				var configFile []byte
+				baseHCLFile, configFile, err = parser.ExtractBackendConfig(baseHCLFile)
+				if err != nil {
+					return err
+				}
+				backendConfigResult = string(configFile)
+			}
+		}
+
+		for _, targetPatch := range conf.TargetPatches {
+			baseHCLFile, err = parser.ApplyTargetPatch(baseHCLFile, targetPatch.Target, targetPatch.Patch)
+			if err != nil {
+				return err
+			}
+		}
+
+		if conf.Versions != nil {
+			baseHCLFile, err = parser.SetVersions(baseHCLFile, *conf.Versions)
+			if err != nil {
+				return err
+			}
+		}
+
+		if conf.VariableDefaults != nil {
+			defaults, err := conf.VariableDefaults.Tokens(filepath.Dir(tfustomizationPath))
+			if err != nil {
+				return err
+			}
+			baseHCLFile, err = parser.SetVariableDefaults(baseHCLFile, defaults)
+			if err != nil {
+				return err
+			}
+		}
+
+		baseHCLFile, err = parser.ApplyReplacements(baseHCLFile, conf.Replacements)
+		if err != nil {
+			return err
+		}
+
+		if conf.Operations != nil {
+			baseHCLFile, err = parser.ApplyOperations(baseHCLFile, conf.Operations.Ops)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, commonAttribute := range conf.CommonAttributes {
+			baseHCLFile, err = parser.SetCommonAttribute(baseHCLFile, commonAttribute.AttributeName(), commonAttribute.ResourceTypes, commonAttribute.Values, commonAttribute.SkipMissing)
+			if err != nil {
+				return err
+			}
+		}
+
+		if conf.Renames != nil {
+			baseHCLFile, err = parser.RenameBlocks(baseHCLFile, conf.Renames.Addresses)
+			if err != nil {
+				return err
+			}
+		}
+
+		baseHCLFile, err = parser.AddNameAffix(baseHCLFile, conf.NamePrefix, conf.NameSuffix, conf.NameAttributes)
+		if err != nil {
+			return err
+		}
+
+		if localizeModules {
+			if print {
+				return fmt.Errorf("--localize-modules cannot be used with --print, since it writes modules into the output directory")
+			}
+			baseHCLFile, err = parser.LocalizeModules(baseHCLFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		tfvarsPaths, err := parser.CollectTfvarsFilePaths(filepath.Dir(tfustomizationPath), append(append([]string{}, conf.Resources.Paths...), conf.Patches.Paths...))
+		if err != nil {
+			return err
+		}
+		var tfvarsResult string
+		if len(tfvarsPaths) != 0 {
+			tfvarsFile, err := parser.MergeTfvarsFiles(tfvarsPaths)
+			if err != nil {
+				return err
+			}
+			tfvarsResult = string(hclwrite.Format(tfvarsFile.Bytes()))
+		}
+
+		lockFilePaths, err := parser.CollectLockFilePaths(filepath.Dir(tfustomizationPath), append(append([]string{}, conf.Resources.Paths...), conf.Patches.Paths...))
+		if err != nil {
+			return err
+		}
+		var lockFileResult string
+		if len(lockFilePaths) != 0 {
+			lockFile, err := parser.MergeLockFiles(lockFilePaths)
+			if err != nil {
+				return err
+			}
+			lockFileResult = string(hclwrite.Format(lockFile.Bytes()))
+		}
+
+		testFilePaths, err := parser.CollectTestFilePaths(filepath.Dir(tfustomizationPath), append(append([]string{}, conf.Resources.Paths...), conf.Patches.Paths...))
+		if err != nil {
+			return err
+		}
+		testFiles, err := parser.MergeTestFiles(testFilePaths)
+		if err != nil {
+			return err
+		}
+
 		result := regexpFormatNewLines.ReplaceAllString(string(hclwrite.Format(baseHCLFile.Bytes())), "\n")
 
 		if print {
 			fmt.Printf("%s", result)
+			if tfvarsResult != "" {
+				slog.Warn("terraform.tfvars is not printed with --print, so build without it to write the file", "files", tfvarsPaths)
+			}
+			if len(testFiles) != 0 {
+				slog.Warn("Test files are not printed with --print, so build without it to write them into the tests directory", "files", testFilePaths)
+			}
+			if lockFileResult != "" {
+				slog.Warn(".terraform.lock.hcl is not printed with --print, so build without it to write the file", "files", lockFilePaths)
+			}
+			if len(parser.Assets) != 0 {
+				slog.Warn("Assets are not copied with --print, so build without it to copy them into the output directory", "paths", conf.Assets.Paths)
+			}
 		} else {
-			outputDirPath := filepath.Join(baseConfDir, outputDir)
 			if _, err := os.Stat(outputDirPath); os.IsNotExist(err) {
 				err := os.Mkdir(outputDirPath, os.ModePerm)
 				if err != nil {
@@ -85,11 +269,55 @@ The command concatenates files specified in the resources and patches blocks, me
 				}
 			}
 
+			if tofu && !cmd.Flags().Changed("outfile") {
+				outputFile = strings.TrimSuffix(outputFile, ".tf") + ".tofu"
+			}
 			outputFilePath := filepath.Join(outputDirPath, outputFile)
 			err := os.WriteFile(outputFilePath, []byte(result), 0666)
 			if err != nil {
 				return err
 			}
+
+			if err := parser.CopyAssets(parser.Assets, outputDirPath); err != nil {
+				return err
+			}
+
+			if len(testFiles) != 0 {
+				testsDirPath := filepath.Join(outputDirPath, "tests")
+				if err := os.MkdirAll(testsDirPath, os.ModePerm); err != nil {
+					return err
+				}
+				for name, testFile := range testFiles {
+					err := os.WriteFile(filepath.Join(testsDirPath, name), hclwrite.Format(testFile.Bytes()), 0666)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			if lockFileResult != "" {
+				lockFilePath := filepath.Join(outputDirPath, ".terraform.lock.hcl")
+				err := os.WriteFile(lockFilePath, []byte(lockFileResult), 0666)
+				if err != nil {
+					return err
+				}
+			}
+
+			if tfvarsResult != "" {
+				tfvarsPath := filepath.Join(outputDirPath, "terraform.tfvars")
+				err := os.WriteFile(tfvarsPath, []byte(tfvarsResult), 0666)
+				if err != nil {
+					return err
+				}
+			}
+
+			if backendConfigResult != "" {
+				backendConfigPath := filepath.Join(outputDirPath, backendConfig)
+				err := os.WriteFile(backendConfigPath, []byte(backendConfigResult), 0666)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
@@ -110,4 +338,8 @@ func init() {
 	buildCmd.Flags().BoolVarP(&print, "print", "p", false, "Print the result to the console instead of writing to a file")
 	buildCmd.Flags().StringVarP(&outputDir, "out", "o", "generated", "Output directory")
 	buildCmd.Flags().StringVarP(&outputFile, "outfile", "f", "main.tf", "Output filename")
+	buildCmd.Flags().StringVar(&backendConfig, "backend-config", "", "Output filename for the settings of the backend block in tfustomization.hcl, to be passed to 'terraform init -backend-config'")
+	buildCmd.Flags().BoolVar(&tofu, "tofu", false, "Write the result as a .tofu file for OpenTofu, unless --outfile is given")
+	buildCmd.Flags().BoolVar(&localizeModules, "localize-modules", false, "Copy local modules into the modules directory of the output directory, so that it's self-contained")
+	buildCmd.Flags().StringArrayVar(&vars, "var", nil, "Set a variable of tfustomization.hcl in the form of key=value. It can be repeated")
 }
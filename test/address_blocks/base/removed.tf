@@ -0,0 +1,8 @@
+resource "aws_instance" "web" {
+  instance_type = "t3.micro"
+}
+
+import {
+  to = module.app.aws_instance.web[0]
+  id = "i-abcd1234"
+}
@@ -0,0 +1,7 @@
+removed {
+  from = aws_instance.web
+}
+
+removed {
+  from = module.app
+}
@@ -0,0 +1 @@
+# lock
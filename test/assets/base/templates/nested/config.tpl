@@ -0,0 +1 @@
+base nested
@@ -0,0 +1,3 @@
+tfustomize {
+  syntax_version = "v1"
+}
@@ -0,0 +1,23 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base/provider.tf",
+  ]
+}
+
+patches {
+  paths = []
+}
+
+backend "s3" {
+  bucket = "tfstate-production"
+  key    = "app/terraform.tfstate"
+  region = "ap-northeast-1"
+
+  assume_role {
+    role_arn = "arn:aws:iam::123456789012:role/terraform"
+  }
+}
@@ -0,0 +1,9 @@
+locals {
+  azs = ["ap-northeast-1a", "ap-northeast-1c"]
+}
+
+resource "aws_instance" "web" {
+  count           = var.instance_count + 1
+  security_groups = ["sg-base"]
+  user_data       = "echo base"
+}
@@ -0,0 +1,19 @@
+data "aws_ami" "ubuntu" {
+  filter {
+    # tfustomize:merge_block:name
+    name   = "name"
+    values = ["ubuntu/images/hvm-ssd/ubuntu-focal-20.04-amd64-server-*"]
+  }
+
+  filter {
+    # tfustomize:merge_block:virtualization
+    name   = "virtualization-type"
+    values = ["hvm"]
+  }
+
+  filter {
+    # tfustomize:merge_block:architecture
+    name   = "architecture"
+    values = ["x86_64"]
+  }
+}
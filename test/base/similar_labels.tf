@@ -0,0 +1,7 @@
+resource "aws_s3" "bucket_logs" {
+  name = "bucket-logs"
+}
+
+resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
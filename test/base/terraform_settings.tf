@@ -0,0 +1,23 @@
+terraform {
+  required_version = ">= 1.5"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+
+  experiments = [module_variable_optional_attrs]
+}
+
+terraform {
+  backend "s3" {
+    bucket = "tfstate-staging"
+    key    = "app/terraform.tfstate"
+    region = "ap-northeast-1"
+  }
+}
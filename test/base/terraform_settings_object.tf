@@ -0,0 +1,9 @@
+terraform {
+  backend "s3" {
+    bucket = "tfstate-staging"
+    assume_role = {
+      role_arn     = "arn:aws:iam::123456789012:role/staging"
+      session_name = "staging"
+    }
+  }
+}
@@ -0,0 +1,23 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base/data_and_resource.tf",
+  ]
+}
+
+patches {
+  paths = [
+    "../overlay/data_and_resource.tf",
+  ]
+
+  patch "resource" "aws_instance" "web" {
+    instance_type = "m5.large"
+  }
+
+  patch "locals" {
+    environment = "production"
+  }
+}
@@ -0,0 +1,15 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+var {
+  region = env("TFUSTOMIZE_TEST_REGION", "ap-northeast-1", "us-east-1")
+}
+
+resources {
+  paths = []
+}
+
+patches {
+  paths = []
+}
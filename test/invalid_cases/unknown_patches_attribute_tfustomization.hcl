@@ -0,0 +1,19 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base/data_and_resource.tf",
+  ]
+}
+
+patches {
+  pathz = [
+    "../overlay/data_and_resource.tf",
+  ]
+
+  patch "locals" {
+    environment = "production"
+  }
+}
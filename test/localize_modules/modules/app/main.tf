@@ -0,0 +1,7 @@
+module "submodule" {
+  source = "./submodule"
+}
+
+module "common" {
+  source = "../common"
+}
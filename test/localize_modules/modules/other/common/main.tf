@@ -0,0 +1 @@
+output "name" { value = "other common" }
@@ -0,0 +1,15 @@
+module "app" {
+  source = "../modules/app"
+}
+
+module "common" {
+  source = "../modules/common"
+}
+
+module "other_common" {
+  source = "../modules/other/common"
+}
+
+module "vpc" {
+  source = "terraform-aws-modules/vpc/aws"
+}
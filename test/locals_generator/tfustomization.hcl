@@ -0,0 +1,27 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base/provider.tf",
+  ]
+}
+
+patches {
+  paths = []
+}
+
+locals_generator {
+  path = "./network.yaml"
+}
+
+locals_generator {
+  path = "./accounts.json"
+  name = "accounts"
+}
+
+locals_generator {
+  path = "./allowed_ips.csv"
+  name = "allowed_ips"
+}
@@ -0,0 +1 @@
+resource "random_id" "id" {}
@@ -0,0 +1,9 @@
+locals {
+  azs = concat(tfustomize_base, ["ap-northeast-1d"])
+}
+
+resource "aws_instance" "web" {
+  count           = tfustomize_base * 2
+  security_groups = concat(tfustomize_base, ["sg-prod"])
+  user_data       = "${tfustomize_base} && echo production"
+}
@@ -0,0 +1,5 @@
+resource "aws_instance" "web" {
+  ebs_block_device {
+    volume_size = tfustomize_base * 2
+  }
+}
@@ -0,0 +1,3 @@
+resource "aws_instance" "batch" {
+  security_groups = concat(tfustomize_base, ["sg-batch"])
+}
@@ -0,0 +1,5 @@
+terraform {
+  backend "s3" {
+    key = "${tfustomize_base}/app"
+  }
+}
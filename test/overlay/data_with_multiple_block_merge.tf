@@ -0,0 +1,11 @@
+data "aws_ami" "ubuntu" {
+  filter {
+    # tfustomize:merge_block:architecture
+    values = ["arm64"]
+  }
+
+  filter {
+    # tfustomize:merge_block:name
+    values = ["ubuntu/images/hvm-ssd/ubuntu-noble-24.04-arm64-server-*"]
+  }
+}
@@ -0,0 +1,12 @@
+provider "aws" {
+  alias = "us_east_1"
+
+  assume_role {
+    role_arn = "arn:aws:iam::123456789012:role/production"
+  }
+}
+
+provider "aws" {
+  alias  = "eu_west_1"
+  region = "eu-west-1"
+}
@@ -0,0 +1,13 @@
+terraform {
+  required_providers {
+    aws = {
+      version = "~> 6.0"
+    }
+  }
+
+  backend "s3" {
+    bucket = "tfstate-production"
+  }
+
+  experiments = [module_variable_optional_attrs, config_driven_move]
+}
@@ -0,0 +1,7 @@
+terraform {
+  backend "s3" {
+    assume_role = {
+      role_arn = "arn:aws:iam::123456789012:role/production"
+    }
+  }
+}
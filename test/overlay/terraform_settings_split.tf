@@ -0,0 +1,15 @@
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+
+terraform {
+  required_providers {
+    aws = {
+      version = "~> 6.0"
+    }
+  }
+}
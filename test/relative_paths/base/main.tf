@@ -0,0 +1,18 @@
+module "app" {
+  source = "../modules/app"
+}
+
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+
+resource "aws_iam_policy" "app" {
+  policy = file("${path.module}/policy.json")
+}
+
+resource "aws_instance" "app" {
+  user_data = templatefile("${path.module}/templates/user_data.sh", {})
+  tags = {
+    Template = "${path.module}/templates/${var.name}.tpl"
+  }
+}
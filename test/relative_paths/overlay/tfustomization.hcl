@@ -0,0 +1,19 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base",
+  ]
+}
+
+patches {
+  patch "module" "extra" {
+    source = "../modules/extra"
+  }
+
+  patch "resource" "aws_iam_policy" "app" {
+    policy = file("${path.module}/policy.json")
+  }
+}
@@ -0,0 +1,3 @@
+run "base_only" {
+  command = plan
+}
@@ -0,0 +1,28 @@
+variables {
+  env           = "staging"
+  instance_type = "t3.micro"
+}
+
+provider "aws" {
+  region = "ap-northeast-1"
+}
+
+run "plan" {
+  command = plan
+
+  variables {
+    name = "web"
+  }
+
+  assert {
+    condition     = aws_instance.web.instance_type == var.instance_type
+    error_message = "instance type is wrong"
+  }
+}
+
+run "apply" {
+  assert {
+    condition     = aws_instance.web.id != ""
+    error_message = "instance is not created"
+  }
+}
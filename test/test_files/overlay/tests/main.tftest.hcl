@@ -0,0 +1,23 @@
+variables {
+  env = "production"
+}
+
+mock_provider "aws" {
+  alias = "mock"
+}
+
+run "plan" {
+  variables {
+    name = "web-production"
+    tier = "frontend"
+  }
+
+  assert {
+    condition     = aws_instance.web.monitoring
+    error_message = "monitoring is disabled"
+  }
+}
+
+run "production_only" {
+  command = plan
+}
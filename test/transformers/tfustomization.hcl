@@ -0,0 +1,75 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base/main.tf",
+  ]
+}
+
+patches {
+  paths = []
+}
+
+name_prefix     = "prod_"
+name_attributes = ["name"]
+
+renames {
+  addresses = {
+    "aws_instance.web" = "aws_instance.app"
+  }
+}
+
+common_attributes {
+  resource_types = ["aws_*"]
+  values = {
+    Environment = "production"
+  }
+}
+
+patch {
+  target {
+    address = "aws_instance.*"
+  }
+
+  monitoring = true
+}
+
+operations {
+  op "test" {
+    path  = "resource.aws_instance.web.instance_type"
+    value = "t3.micro"
+  }
+
+  op "replace" {
+    path  = "resource.aws_instance.web.instance_type"
+    value = "m5.large"
+  }
+}
+
+placeholders = {
+  env = "production"
+}
+
+replacement {
+  source  = "locals.ami"
+  targets = ["resource.aws_instance.*.ami"]
+}
+
+replacement {
+  value   = "m5.large"
+  targets = ["resource.aws_instance.*.instance_type"]
+}
+
+versions {
+  module {
+    source  = "terraform-aws-modules/vpc/aws"
+    version = "5.1.0"
+  }
+
+  provider {
+    name    = "aws"
+    version = "~> 5.0"
+  }
+}
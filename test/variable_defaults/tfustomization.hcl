@@ -0,0 +1,20 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+resources {
+  paths = [
+    "../base/all_blocks.tf",
+  ]
+}
+
+patches {
+  paths = []
+}
+
+variable_defaults {
+  tfvars = "./production.tfvars"
+  values = {
+    instance_type = "m5.large"
+  }
+}
@@ -0,0 +1,24 @@
+tfustomize {
+  syntax_version = "v1"
+}
+
+var {
+  env    = "staging"
+  region = upper(env("TFUSTOMIZE_TEST_REGION", "ap-northeast-1"))
+}
+
+resources {
+  paths = [
+    "../base/${var.env}.tf",
+  ]
+}
+
+patches {
+  paths = []
+}
+
+backend "s3" {
+  bucket = "tfstate-${var.env}"
+  key    = join("/", ["app", var.env, "terraform.tfstate"])
+  region = lower(var.region)
+}